@@ -0,0 +1,94 @@
+package tinylfu
+
+import (
+	"github.com/dgryski/go-tinylfu/bloom"
+	"github.com/dgryski/go-tinylfu/sketch"
+)
+
+// admission is the TinyLFU admission policy: a frequency sketch aged every
+// samples accesses, fronted by a doorkeeper filtering one-hit wonders
+type admission struct {
+	c       *sketch.Sketch
+	bouncer *bloom.Filter
+	w       int
+	samples int
+
+	fill float64 // doorkeeper fill ratio at the last reset
+	fpr  float64 // doorkeeper false positive rate at the last reset
+	rnd  uint64  // xorshift state
+}
+
+func newAdmission(size, samples int) admission {
+	return admission{
+		c:       sketch.New(size),
+		w:       0,
+		samples: samples,
+		bouncer: bloom.New(samples, 0.01),
+		rnd:     0x9e3779b97f4a7c15,
+	}
+}
+
+// count records an access to keyh in the frequency sketch, aging the sketch
+// once the sample window is full.  It returns whether the sketch was aged.
+func (a *admission) count(keyh uint64) (reset bool) {
+	a.w++
+	if a.w == a.samples {
+		a.measure()
+		a.c.Reset()
+		a.bouncer.Reset()
+		a.w = 0
+		reset = true
+	}
+
+	a.c.Add(keyh)
+	return reset
+}
+
+// rand returns the next value of an xorshift64* generator
+func (a *admission) rand() uint64 {
+	a.rnd ^= a.rnd >> 12
+	a.rnd ^= a.rnd << 25
+	a.rnd ^= a.rnd >> 27
+	return a.rnd * 2685821657736338717
+}
+
+// admit reports whether candidate should replace victim
+func (a *admission) admit(candidate, victim uint64) bool {
+	if !a.bouncer.Insert(candidate) {
+		return false
+	}
+	return a.c.Estimate(candidate) >= a.c.Estimate(victim)
+}
+
+// Admitter is the TinyLFU admission policy on its own, for use in front of
+// storage other than this package's caches, such as a remote or on-disk
+// cache.  Keys are identified by their 64-bit hashes.  It is not safe for
+// concurrent access.
+type Admitter struct {
+	admission
+}
+
+// NewAdmitter returns an admission policy for a cache holding size items,
+// aging its frequency estimates every samples accesses.
+func NewAdmitter(size, samples int) *Admitter {
+	return &Admitter{newAdmission(size, samples)}
+}
+
+// Record records an access to the key with hash h.
+func (a *Admitter) Record(h uint64) {
+	a.count(h)
+}
+
+// Admit reports whether the candidate key should be admitted to the cache,
+// evicting the victim key that would make room for it.  The candidate is
+// admitted if it has been seen before and is at least as popular as the
+// victim.
+func (a *Admitter) Admit(candidate, victim uint64) bool {
+	return a.admit(candidate, victim)
+}
+
+// Estimate returns the estimated number of recent accesses to the key with
+// hash h.
+func (a *Admitter) Estimate(h uint64) int {
+	return int(a.c.Estimate(h))
+}
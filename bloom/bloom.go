@@ -0,0 +1,172 @@
+// Package bloom implements a small bloom filter using double hashing, as used
+// by TinyLFU's doorkeeper to filter out keys seen only once.
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// Filter is a bloom filter over 64-bit key hashes.  It is not safe for
+// concurrent access.
+type Filter struct {
+	m      uint32    // size of bit vector in bits
+	k      uint32    // distinct hash functions needed
+	filter bitvector // our filter bit vector
+}
+
+// ErrMismatch is returned when combining filters with different parameters.
+var ErrMismatch = errors.New("bloom: mismatched filters")
+
+// ErrCorrupt is returned when unmarshalling malformed data.
+var ErrCorrupt = errors.New("bloom: corrupt data")
+
+// New returns a filter sized to hold capacity keys with the given false
+// positive rate.
+func New(capacity int, falsePositiveRate float64) *Filter {
+	bits := float64(capacity) * -math.Log(falsePositiveRate) / (math.Log(2.0) * math.Log(2.0)) // in bits
+	m := nextPowerOfTwo(uint32(bits))
+
+	if m < 1024 {
+		m = 1024
+	}
+
+	k := uint32(0.7 * float64(m) / float64(capacity))
+	if k < 2 {
+		k = 2
+	}
+
+	return &Filter{
+		m:      m,
+		filter: newbv(m),
+		k:      k,
+	}
+}
+
+// Insert inserts the hash h into the bloom filter.  Returns true if the value
+// was already considered to be in the bloom filter.
+func (d *Filter) Insert(h uint64) bool {
+	h1, h2 := uint32(h), uint32(h>>32)
+	var o uint = 1
+	for i := uint32(0); i < d.k; i++ {
+		o &= d.filter.getset((h1 + (i * h2)) & (d.m - 1))
+	}
+	return o == 1
+}
+
+// Test reports whether h is considered to be in the bloom filter, without
+// inserting it.
+func (d *Filter) Test(h uint64) bool {
+	h1, h2 := uint32(h), uint32(h>>32)
+	for i := uint32(0); i < d.k; i++ {
+		if !d.filter.get((h1 + (i * h2)) & (d.m - 1)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset clears the bloom filter
+func (d *Filter) Reset() {
+	for i := range d.filter {
+		d.filter[i] = 0
+	}
+}
+
+// Compatible reports whether o has the same parameters as d, and so can be
+// combined with it.
+func (d *Filter) Compatible(o *Filter) bool {
+	return d.m == o.m && d.k == o.k
+}
+
+// Union adds the contents of o to d.  The filters must have been created with
+// the same parameters.
+func (d *Filter) Union(o *Filter) error {
+	if !d.Compatible(o) {
+		return ErrMismatch
+	}
+	for i := range d.filter {
+		d.filter[i] |= o.filter[i]
+	}
+	return nil
+}
+
+// FillRatio returns the fraction of the filter's bits which are set.  The
+// false positive rate is approximately FillRatio raised to the number of hash
+// functions.
+func (d *Filter) FillRatio() float64 {
+	var n int
+	for _, w := range d.filter {
+		n += bits.OnesCount64(w)
+	}
+	return float64(n) / float64(d.m)
+}
+
+// MarshalBinary encodes the filter.
+func (d *Filter) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8, 8+8*len(d.filter))
+	binary.LittleEndian.PutUint32(b, d.m)
+	binary.LittleEndian.PutUint32(b[4:], d.k)
+	for _, w := range d.filter {
+		b = binary.LittleEndian.AppendUint64(b, w)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces d with the filter encoded in b.
+func (d *Filter) UnmarshalBinary(b []byte) error {
+	if len(b) < 8 {
+		return ErrCorrupt
+	}
+
+	m := binary.LittleEndian.Uint32(b)
+	k := binary.LittleEndian.Uint32(b[4:])
+	b = b[8:]
+	if m < 64 || m&(m-1) != 0 || k < 1 || uint64(len(b)) != uint64(m)/8 {
+		return ErrCorrupt
+	}
+
+	filter := newbv(m)
+	for i := range filter {
+		filter[i] = binary.LittleEndian.Uint64(b[8*i:])
+	}
+
+	*d = Filter{m: m, k: k, filter: filter}
+	return nil
+}
+
+// Internal routines for the bit vector
+type bitvector []uint64
+
+func newbv(size uint32) bitvector {
+	return make([]uint64, uint(size+63)/64)
+}
+
+// get returns the value of bit 'bit' in the bitvector
+func (b bitvector) get(bit uint32) bool {
+	return b[bit/64]&(uint64(1)<<(bit%64)) != 0
+}
+
+// set bit 'bit' in the bitvector d and return previous value
+func (b bitvector) getset(bit uint32) uint {
+	shift := bit % 64
+	idx := bit / 64
+	bb := b[idx]
+	m := uint64(1) << shift
+	b[idx] |= m
+	return uint((bb & m) >> shift)
+}
+
+// return the integer >= i which is a power of two
+func nextPowerOfTwo(i uint32) uint32 {
+	n := i - 1
+	n |= n >> 1
+	n |= n >> 2
+	n |= n >> 4
+	n |= n >> 8
+	n |= n >> 16
+	n++
+	return n
+}
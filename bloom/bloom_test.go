@@ -0,0 +1,75 @@
+package bloom
+
+import (
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	f := New(100, 0.01)
+
+	h := uint64(0x0ddc0ffeebadf00d)
+
+	if f.Test(h) {
+		t.Errorf("f.Test(%x)=true on empty filter", h)
+	}
+	if f.Insert(h) {
+		t.Errorf("f.Insert(%x)=true on first insert", h)
+	}
+	if !f.Insert(h) {
+		t.Errorf("f.Insert(%x)=false on second insert", h)
+	}
+	if !f.Test(h) {
+		t.Errorf("f.Test(%x)=false after insert", h)
+	}
+
+	if r, want := f.FillRatio(), float64(f.k)/float64(f.m); r != want {
+		t.Errorf("f.FillRatio()=%v, want %v", r, want)
+	}
+
+	f.Reset()
+	if f.Test(h) || f.FillRatio() != 0 {
+		t.Errorf("filter not empty after reset")
+	}
+}
+
+func TestUnion(t *testing.T) {
+	a, b := New(100, 0.01), New(100, 0.01)
+
+	h1, h2 := uint64(0x0ddc0ffeebadf00d), uint64(0x1234567887654321)
+	a.Insert(h1)
+	b.Insert(h2)
+
+	if err := a.Union(b); err != nil {
+		t.Fatalf("a.Union(b)=%v", err)
+	}
+	if !a.Test(h1) || !a.Test(h2) {
+		t.Errorf("union missing inserted hashes")
+	}
+
+	if err := a.Union(New(10000, 0.01)); err != ErrMismatch {
+		t.Errorf("a.Union(larger)=%v, want %v", err, ErrMismatch)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	f := New(100, 0.01)
+	h := uint64(0x0ddc0ffeebadf00d)
+	f.Insert(h)
+
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Filter
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary()=%v", err)
+	}
+	if !got.Test(h) || got.m != f.m || got.k != f.k {
+		t.Errorf("UnmarshalBinary() didn't round trip")
+	}
+
+	if err := got.UnmarshalBinary(b[:len(b)-1]); err != ErrCorrupt {
+		t.Errorf("UnmarshalBinary(truncated)=%v, want %v", err, ErrCorrupt)
+	}
+}
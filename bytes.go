@@ -0,0 +1,243 @@
+package tinylfu
+
+import (
+	"hash/maphash"
+
+	"github.com/dgryski/go-tinylfu/internal/ilist"
+)
+
+// bentry is the metadata for a BytesCache entry.  The key and value bytes
+// are stored back to back in the arena starting at off.
+type bentry struct {
+	keyh   uint64
+	off    int
+	klen   int
+	vlen   int
+	listid int
+}
+
+// BytesCache is a TinyLFU cache specialised for string keys and []byte
+// values.  Keys and values are copied into a single arena slab and entries are
+// linked together by index, so the cache holds a handful of pointers no matter
+// how many entries it contains and puts very little load on the garbage
+// collector.  It is not safe for concurrent access.
+type BytesCache struct {
+	admission
+
+	seed    maphash.Seed
+	index   map[uint64]int32 // key hash to entry
+	entries []bentry
+	lists   *ilist.Lists
+	caps    [nlists]int
+
+	arena []byte
+	live  int // bytes in the arena referenced by entries
+
+	stats Stats
+}
+
+// NewBytes returns a BytesCache holding up to size entries, aging its
+// frequency sketch every samples accesses.
+func NewBytes(size int, samples int) *BytesCache {
+	window, probation, protected := segmentSizes(size)
+	n := window + probation + protected
+
+	c := &BytesCache{
+		admission: newAdmission(size, samples),
+
+		seed:    maphash.MakeSeed(),
+		index:   make(map[uint64]int32, n),
+		entries: make([]bentry, n),
+		lists:   ilist.New(n, nlists),
+		caps:    [nlists]int{listWindow: window, listOne: probation, listTwo: protected},
+	}
+
+	for i := 0; i < n; i++ {
+		c.entries[i].listid = listFree
+		c.lists.PushBack(listFree, int32(i))
+	}
+
+	return c
+}
+
+// Len returns the number of entries in the cache.
+func (c *BytesCache) Len() int {
+	return len(c.index)
+}
+
+// Get returns the value stored for key.  The returned slice aliases the
+// cache's storage and must not be modified.
+func (c *BytesCache) Get(key string) ([]byte, bool) {
+	keyh := maphash.String(c.seed, key)
+
+	c.count(keyh)
+
+	i, ok := c.lookup(key, keyh)
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.stats.Hits++
+	c.touch(i)
+
+	e := &c.entries[i]
+	v := e.off + e.klen
+	return c.arena[v : v+e.vlen : v+e.vlen], true
+}
+
+// Add stores a copy of val for key.
+func (c *BytesCache) Add(key string, val []byte) {
+	keyh := maphash.String(c.seed, key)
+
+	if i, ok := c.index[keyh]; ok {
+		if c.key(i) == key {
+			c.c.Add(keyh)
+			c.release(i)
+			c.store(i, key, val)
+			c.touch(i)
+			return
+		}
+
+		// A different key with the same hash; the newer key wins.
+		c.stats.Evictions++
+		c.remove(i)
+	}
+
+	if c.lists.Len(listWindow) >= c.caps[listWindow] {
+		c.demote()
+	}
+
+	i := c.lists.Front(listFree)
+	c.lists.Remove(listFree, i)
+	c.entries[i] = bentry{keyh: keyh, listid: listWindow}
+	c.store(i, key, val)
+	c.index[keyh] = i
+	c.lists.PushFront(listWindow, i)
+}
+
+// lookup returns the entry for key, if present
+func (c *BytesCache) lookup(key string, keyh uint64) (int32, bool) {
+	i, ok := c.index[keyh]
+	if !ok || c.key(i) != key {
+		return 0, false
+	}
+	return i, true
+}
+
+// key returns the key bytes of entry i
+func (c *BytesCache) key(i int32) string {
+	e := &c.entries[i]
+	// The conversion doesn't allocate when used in a comparison.
+	return string(c.arena[e.off : e.off+e.klen])
+}
+
+// touch updates the lists for an access to entry i
+func (c *BytesCache) touch(i int32) {
+	e := &c.entries[i]
+
+	switch e.listid {
+	case listWindow, listTwo:
+		c.lists.MoveToFront(e.listid, i)
+		return
+	}
+
+	// tiny caches have no room for a protected segment
+	if c.caps[listTwo] == 0 {
+		c.lists.MoveToFront(listOne, i)
+		return
+	}
+
+	// on probation: promote to protected, demoting the protected tail if full
+	c.lists.Remove(listOne, i)
+	if c.lists.Len(listTwo) >= c.caps[listTwo] {
+		b := c.lists.Back(listTwo)
+		c.lists.Remove(listTwo, b)
+		c.entries[b].listid = listOne
+		c.lists.PushFront(listOne, b)
+	}
+	e.listid = listTwo
+	c.lists.PushFront(listTwo, i)
+}
+
+// demote moves the tail of the window into the main cache if TinyLFU admits
+// it, evicting either the candidate or the probation victim.
+func (c *BytesCache) demote() {
+	i := c.lists.Back(listWindow)
+	c.lists.Remove(listWindow, i)
+
+	if c.lists.Len(listOne)+c.lists.Len(listTwo) < c.caps[listOne]+c.caps[listTwo] {
+		c.entries[i].listid = listOne
+		c.lists.PushFront(listOne, i)
+		return
+	}
+
+	keyh := c.entries[i].keyh
+	v := c.lists.Back(listOne)
+	if !c.admit(keyh, c.entries[v].keyh) {
+		c.stats.Rejections++
+		c.stats.Evictions++
+		c.free(i)
+		return
+	}
+
+	c.stats.Evictions++
+	c.remove(v)
+	c.entries[i].listid = listOne
+	c.lists.PushFront(listOne, i)
+}
+
+// remove deletes entry i from the cache
+func (c *BytesCache) remove(i int32) {
+	c.lists.Remove(c.entries[i].listid, i)
+	c.free(i)
+}
+
+// free returns entry i, which must not be on a list, to the free list
+func (c *BytesCache) free(i int32) {
+	e := &c.entries[i]
+	delete(c.index, e.keyh)
+	c.release(i)
+	*e = bentry{listid: listFree}
+	c.lists.PushFront(listFree, i)
+}
+
+// release marks the arena bytes of entry i as garbage
+func (c *BytesCache) release(i int32) {
+	e := &c.entries[i]
+	c.live -= e.klen + e.vlen
+	e.klen, e.vlen = 0, 0
+}
+
+// store copies key and val into the arena for entry i
+func (c *BytesCache) store(i int32, key string, val []byte) {
+	n := len(key) + len(val)
+	if len(c.arena)+n > cap(c.arena) && c.live < len(c.arena)/2 {
+		c.compact(n)
+	}
+
+	e := &c.entries[i]
+	e.off = len(c.arena)
+	e.klen = len(key)
+	e.vlen = len(val)
+	c.arena = append(c.arena, key...)
+	c.arena = append(c.arena, val...)
+	c.live += n
+}
+
+// compact copies the live entries to a fresh arena with room for at least
+// extra more bytes.  Slices returned by Get continue to refer to the old
+// arena, which is never written to again.
+func (c *BytesCache) compact(extra int) {
+	arena := make([]byte, 0, 2*(c.live+extra))
+	for i := range c.entries {
+		e := &c.entries[i]
+		if e.listid == listFree {
+			continue
+		}
+		off := len(arena)
+		arena = append(arena, c.arena[e.off:e.off+e.klen+e.vlen]...)
+		e.off = off
+	}
+	c.arena = arena
+}
@@ -0,0 +1,84 @@
+package tinylfu
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBytesCache(t *testing.T) {
+	c := NewBytes(100, 1000)
+
+	c.Add("foo", []byte("bar"))
+	if v, ok := c.Get("foo"); !ok || string(v) != "bar" {
+		t.Errorf("c.Get(foo)=%q,%v, want %q,true", v, ok, "bar")
+	}
+
+	c.Add("foo", []byte("baz"))
+	if v, ok := c.Get("foo"); !ok || string(v) != "baz" {
+		t.Errorf("c.Get(foo)=%q,%v, want %q,true", v, ok, "baz")
+	}
+
+	if _, ok := c.Get("bar"); ok {
+		t.Errorf("c.Get(bar) found, want miss")
+	}
+}
+
+func TestBytesCacheEviction(t *testing.T) {
+	const size = 100
+	c := NewBytes(size, 1000)
+
+	for i := 0; i < 10*size; i++ {
+		k := strconv.Itoa(i)
+		c.Add(k, []byte("value "+k))
+
+		for j := 0; j <= i; j += 7 {
+			k := strconv.Itoa(j)
+			if v, ok := c.Get(k); ok && string(v) != "value "+k {
+				t.Fatalf("c.Get(%s)=%q, want %q", k, v, "value "+k)
+			}
+		}
+
+		if c.Len() > size {
+			t.Fatalf("c.Len()=%d, want <= %d", c.Len(), size)
+		}
+	}
+
+	var live int
+	for _, e := range c.entries {
+		live += e.klen + e.vlen
+	}
+	if live != c.live {
+		t.Errorf("live=%d, want %d", c.live, live)
+	}
+	if len(c.arena) > 4*c.live {
+		t.Errorf("arena=%d not compacted, live=%d", len(c.arena), c.live)
+	}
+}
+
+func BenchmarkBytesGet(b *testing.B) {
+	c := NewBytes(64, 640)
+	key := "some arbitrary key"
+	val := []byte("some arbitrary value")
+	c.Add(key, val)
+	for i := 0; i < b.N; i++ {
+		_, SinkBool = c.Get(key)
+	}
+}
+
+func TestBytesCacheTiny(t *testing.T) {
+	// a cache this small has no room for a protected segment
+	c := NewBytes(2, 100)
+
+	for i := 0; i < 10; i++ {
+		k := strconv.Itoa(i % 3)
+		c.Add(k, []byte(k))
+		for j := 0; j < 3; j++ {
+			if v, ok := c.Get(k); !ok || string(v) != k {
+				t.Fatalf("c.Get(%s)=%q,%v, want %q,true", k, v, ok, k)
+			}
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("c.Len()=%d, want 2", c.Len())
+	}
+}
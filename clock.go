@@ -0,0 +1,79 @@
+package tinylfu
+
+import "github.com/dgryski/go-tinylfu/internal/ilist"
+
+// clockCache is a CLOCK cache.  Items form a ring swept by the hand, here
+// the back of the list; a hit sets an item's reference bit rather than
+// moving it, and the hand gives referenced items a second chance.
+type clockCache[K comparable, V any] struct {
+	*slots[K, V]
+	cap int
+}
+
+func newClock[K comparable, V any](cap int, s *slots[K, V]) *clockCache[K, V] {
+	return &clockCache[K, V]{
+		slots: s,
+		cap:   cap,
+	}
+}
+
+// get sets the reference bit of the item in slot i
+func (c *clockCache[K, V]) get(i int32) {
+	c.items[i].freq = 1
+}
+
+// add adds a value to the cache
+func (c *clockCache[K, V]) add(newitem slruItem[K, V]) (oitem slruItem[K, V], evicted bool) {
+	newitem.freq = 0
+
+	if c.Len() < c.cap {
+		c.alloc(listOne, newitem)
+		return slruItem[K, V]{}, false
+	}
+
+	// reuse the victim's slot
+	c.victim()
+	return c.replace(listOne, newitem), true
+}
+
+// victim advances the hand to the first unreferenced item
+func (c *clockCache[K, V]) victim() *slruItem[K, V] {
+	if c.Len() < c.cap {
+		return nil
+	}
+
+	for {
+		b := c.ll.Back(listOne)
+		item := &c.items[b]
+		if item.freq == 0 {
+			return item
+		}
+		item.freq = 0
+		c.ll.MoveToFront(listOne, b)
+	}
+}
+
+// peek returns the first unreferenced item from the hand.  If every item is
+// referenced, the hand clears them all and comes back round to where it
+// started.
+func (c *clockCache[K, V]) peek() *slruItem[K, V] {
+	if c.Len() < c.cap {
+		return nil
+	}
+
+	for b := c.ll.Back(listOne); b != ilist.Nil; b = c.ll.Prev(listOne, b) {
+		if c.items[b].freq == 0 {
+			return &c.items[b]
+		}
+	}
+	return &c.items[c.ll.Back(listOne)]
+}
+
+func (c *clockCache[K, V]) segment(i int32) Segment {
+	return SegmentMain
+}
+
+// Len returns the total number of items in the cache
+func (c *clockCache[K, V]) Len() int {
+	return c.ll.Len(listOne)
+}
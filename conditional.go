@@ -0,0 +1,104 @@
+package tinylfu
+
+// ComputeOp is the action Compute takes with the value returned by its function.
+type ComputeOp int
+
+const (
+	// ComputeSet stores the returned value.
+	ComputeSet ComputeOp = iota
+	// ComputeKeep leaves the cache unchanged.
+	ComputeKeep
+	// ComputeDelete removes the key from the cache.
+	ComputeDelete
+)
+
+// AddIfAbsent adds val for key unless key is already cached.  It returns the
+// cached value and true if key was present, counting as a Get, or val and
+// false if it was added.
+func (t *T[K, V]) AddIfAbsent(key K, val V) (actual V, loaded bool) {
+	if i, ok := t.lookup(key); ok {
+		return t.hit(i, t.record(key, AccessGet)), true
+	}
+
+	keyh := t.hash(key)
+	if i, ok := t.unspill(key, keyh); ok {
+		return t.hit(i, t.record(key, AccessGet)), true
+	}
+
+	t.add(key, keyh, val)
+	return val, false
+}
+
+// lookupAll is lookup, also moving key back into the cache from the
+// second-level store
+func (t *T[K, V]) lookupAll(key K) (int32, bool) {
+	i, ok := t.lookup(key)
+	if !ok && t.l2 != nil {
+		i, ok = t.unspill(key, t.hash(key))
+	}
+	return i, ok
+}
+
+// CompareAndSwapFunc replaces the value for key with new if key is cached and
+// eq reports that its current value is equal to old.  It returns whether the
+// swap happened.  A successful swap acts like Add.
+func (t *T[K, V]) CompareAndSwapFunc(key K, old, new V, eq func(a, b V) bool) bool {
+	i, ok := t.lookupAll(key)
+	if !ok || !eq(t.items[i].value, old) {
+		return false
+	}
+
+	t.update(i, new)
+	return true
+}
+
+// CompareAndSwap is CompareAndSwapFunc for comparable values, using ==.
+func CompareAndSwap[K, V comparable](t *T[K, V], key K, old, new V) bool {
+	return t.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
+}
+
+// Compute calls f with the current value for key and whether it is cached,
+// then sets, keeps or deletes the value according to the returned op.
+//
+// Setting the value of a cached key updates it in place: unlike Add, the item
+// keeps its position in the cache's lists and its frequency isn't bumped.
+// Setting the value of a new key adds it as Add would.  Deleting a key is
+// reported to OnRemove.  f may itself use the cache; the key is looked up
+// again once f returns, and the op applies to whatever is cached then.
+func (t *T[K, V]) Compute(key K, f func(old V, ok bool) (V, ComputeOp)) {
+	i, ok := t.lookupAll(key)
+
+	var old V
+	if ok {
+		old = t.items[i].value
+	}
+
+	val, op := f(old, ok)
+	if op == ComputeKeep {
+		return
+	}
+
+	// f may have added, moved or evicted items
+	i, ok = t.lookupAll(key)
+
+	switch op {
+	case ComputeSet:
+		if !ok {
+			t.add(key, t.hash(key), val)
+			return
+		}
+		cur := t.items[i].value
+		t.items[i].value = val
+		t.replace(key, cur)
+
+	case ComputeDelete:
+		if t.l2 != nil {
+			t.l2.Remove(key)
+		}
+		if ok {
+			cur := t.items[i].value
+			t.slots.free(i)
+			t.remove(key, cur)
+		}
+	}
+}
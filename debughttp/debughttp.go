@@ -0,0 +1,98 @@
+// Package debughttp serves a plain-text view of a tinylfu cache's internal
+// state over HTTP, for inspecting live caches:
+//
+//	http.Handle("/debug/cache/users", debughttp.New("users", func(n int) tinylfu.Inspection[string] {
+//		mu.Lock()
+//		defer mu.Unlock()
+//		return cache.Inspect(n)
+//	}))
+//
+// The number of hot keys shown can be set with the keys query parameter.
+package debughttp
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	tinylfu "github.com/dgryski/go-tinylfu"
+)
+
+// DefaultKeys is the number of hot keys shown if the request doesn't say.
+const DefaultKeys = 20
+
+// Handler renders the state of a named cache.
+type Handler[K comparable] struct {
+	name    string
+	inspect func(hot int) tinylfu.Inspection[K]
+}
+
+// New returns a handler for the cache called name.  inspect is called from
+// the goroutine serving each request, so it must be safe to call
+// concurrently with the cache's other operations.  *tinylfu.T is not safe
+// for concurrent access; wrap its Inspect method in a function which takes
+// the lock guarding the cache.
+func New[K comparable](name string, inspect func(hot int) tinylfu.Inspection[K]) *Handler[K] {
+	return &Handler[K]{name: name, inspect: inspect}
+}
+
+func (h *Handler[K]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	keys := DefaultKeys
+	if s := r.FormValue("keys"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "bad keys parameter", http.StatusBadRequest)
+			return
+		}
+		keys = n
+	}
+
+	in := h.inspect(keys)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "cache\t%s\n\n", h.name)
+
+	st := in.Stats
+	fmt.Fprintf(tw, "hits\t%d\n", st.Hits)
+	fmt.Fprintf(tw, "misses\t%d\n", st.Misses)
+	fmt.Fprintf(tw, "negative hits\t%d\n", st.Negatives)
+	fmt.Fprintf(tw, "l2 hits\t%d\n", st.L2Hits)
+	fmt.Fprintf(tw, "evictions\t%d\n", st.Evictions)
+	fmt.Fprintf(tw, "rejections\t%d\n", st.Rejections)
+	fmt.Fprintf(tw, "size\t%d\n\n", st.Size)
+
+	segments := make([]tinylfu.Segment, 0, len(in.Segments))
+	for s := range in.Segments {
+		segments = append(segments, s)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	fmt.Fprintf(tw, "segment\titems\n")
+	for _, s := range segments {
+		fmt.Fprintf(tw, "%v\t%d\n", s, in.Segments[s])
+	}
+	fmt.Fprintln(tw)
+
+	hl := in.Health
+	fmt.Fprintf(tw, "sketch saturation\t%.2f%%\n", 100*hl.Saturation)
+	fmt.Fprintf(tw, "sketch mean\t%.2f\n", hl.Mean)
+	fmt.Fprintf(tw, "doorkeeper fill\t%.2f%%\n", 100*in.FillRatio)
+	fmt.Fprintf(tw, "doorkeeper fill at reset\t%.2f%%\n", 100*hl.FillRatio)
+	fmt.Fprintf(tw, "doorkeeper false positives at reset\t%.2f%%\n\n", 100*hl.FalsePositiveRate)
+
+	if in.HotKeys == nil {
+		fmt.Fprintf(tw, "hot keys not tracked\n")
+	} else {
+		fmt.Fprintf(tw, "hot key\tfrequency\n")
+		for _, k := range in.HotKeys {
+			fmt.Fprintf(tw, "%v\t%d\n", k.Key, k.Frequency)
+		}
+	}
+
+	tw.Flush()
+}
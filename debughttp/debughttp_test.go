@@ -0,0 +1,56 @@
+package debughttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tinylfu "github.com/dgryski/go-tinylfu"
+)
+
+func TestHandler(t *testing.T) {
+	c := tinylfu.New[int, int](100, 1000, func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 },
+		tinylfu.TrackTopK[int, int](10),
+	)
+	for i := 0; i < 200; i++ {
+		c.Add(i, i)
+	}
+	for i := 0; i < 5; i++ {
+		c.Get(42)
+	}
+
+	h := New("ints", c.Inspect)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?keys=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", rec.Code)
+	}
+	// ignore the column alignment
+	var lines []string
+	for _, l := range strings.Split(rec.Body.String(), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(l), " "))
+	}
+	out := strings.Join(lines, "\n")
+
+	for _, want := range []string{
+		"cache ints\n",
+		"hits 5\n",
+		"size 100\n",
+		"window 1\n",
+		"sketch saturation 0.00%\n",
+		"doorkeeper fill ",
+		"hot key frequency\n42 5",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?keys=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status %d for bad keys, want 400", rec.Code)
+	}
+}
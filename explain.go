@@ -0,0 +1,141 @@
+package tinylfu
+
+// Segment identifies the part of the cache holding an item.
+type Segment int
+
+const (
+	// SegmentNone means the key is not in the cache.
+	SegmentNone Segment = iota
+	// SegmentWindow is the admission window LRU.
+	SegmentWindow
+	// SegmentProbation is the probationary segment of the main SLRU.
+	SegmentProbation
+	// SegmentProtected is the protected segment of the main SLRU.
+	SegmentProtected
+	// SegmentPinned holds pinned items, which are never evicted.
+	SegmentPinned
+	// SegmentMain is the main cache, for policies without segments.
+	SegmentMain
+	// SegmentNegative holds negative entries.
+	SegmentNegative
+)
+
+func (s Segment) String() string {
+	switch s {
+	case SegmentNone:
+		return "none"
+	case SegmentWindow:
+		return "window"
+	case SegmentProbation:
+		return "probation"
+	case SegmentProtected:
+		return "protected"
+	case SegmentPinned:
+		return "pinned"
+	case SegmentMain:
+		return "main"
+	case SegmentNegative:
+		return "negative"
+	}
+	return "unknown"
+}
+
+// segmentOf returns the segment holding the item in slot i
+func (t *T[K, V]) segmentOf(i int32) Segment {
+	switch t.items[i].listid {
+	case listWindow:
+		return SegmentWindow
+	case listPinned:
+		return SegmentPinned
+	case listNegative:
+		return SegmentNegative
+	}
+	return t.main.segment(i)
+}
+
+// Outcome is the result of a key competing for admission to the main cache.
+type Outcome int
+
+const (
+	// OutcomeCached means the key is already in the main cache or pinned.
+	OutcomeCached Outcome = iota
+	// OutcomeAdmitted means the main cache has room for the key.
+	OutcomeAdmitted
+	// OutcomeAdmittedOverVictim means the key would be admitted, evicting
+	// the victim.
+	OutcomeAdmittedOverVictim
+	// OutcomeRejectedDoorkeeper means the key would be rejected because the
+	// doorkeeper hasn't seen it since the sketch was last reset.
+	OutcomeRejectedDoorkeeper
+	// OutcomeRejectedFrequency means the key would be rejected because it is
+	// estimated to be less popular than the victim.
+	OutcomeRejectedFrequency
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeCached:
+		return "cached"
+	case OutcomeAdmitted:
+		return "admitted"
+	case OutcomeAdmittedOverVictim:
+		return "admitted over victim"
+	case OutcomeRejectedDoorkeeper:
+		return "rejected by doorkeeper"
+	case OutcomeRejectedFrequency:
+		return "rejected by frequency"
+	}
+	return "unknown"
+}
+
+// Explanation describes the cache's view of a key.
+type Explanation[K comparable] struct {
+	Segment   Segment // where the key is cached
+	Frequency int     // the key's estimated access count, from the sketch alone
+	Seen      bool    // whether the doorkeeper has seen the key
+
+	HasVictim       bool // whether the main cache is full
+	Victim          K    // the next item to be evicted from the main cache
+	VictimFrequency int  // the victim's estimated access count
+
+	// Outcome is what would happen if the key, whether new or in the
+	// window, competed with the victim for admission to the main cache now.
+	Outcome Outcome
+}
+
+// Explain reports why key is or isn't cached, and what would happen if it
+// competed for admission now.  It doesn't modify the cache.
+func (t *T[K, V]) Explain(key K) Explanation[K] {
+	var e Explanation[K]
+
+	if i, ok := t.data[key]; ok && !t.stale(&t.items[i]) && !t.expired(&t.items[i]) {
+		e.Segment = t.segmentOf(i)
+	}
+
+	keyh := t.keyHash(key)
+
+	e.Frequency = int(t.c.Estimate(keyh))
+	e.Seen = t.bouncer.Test(keyh)
+
+	victim := t.main.peek()
+	if victim != nil {
+		e.HasVictim = true
+		e.Victim = victim.key
+		e.VictimFrequency = int(t.c.Estimate(victim.keyh))
+	}
+
+	switch {
+	case e.Segment != SegmentNone && e.Segment != SegmentWindow && e.Segment != SegmentNegative:
+		e.Outcome = OutcomeCached
+	case victim == nil:
+		e.Outcome = OutcomeAdmitted
+	case !e.Seen:
+		e.Outcome = OutcomeRejectedDoorkeeper
+	case e.Frequency < e.VictimFrequency:
+		e.Outcome = OutcomeRejectedFrequency
+	default:
+		e.Outcome = OutcomeAdmittedOverVictim
+	}
+
+	return e
+}
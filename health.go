@@ -0,0 +1,46 @@
+package tinylfu
+
+// Health describes how well the admission policy's frequency sketch and
+// doorkeeper are sized for the workload.  A sketch with many saturated
+// counters, or a doorkeeper which is nearly full by the time it is reset,
+// can't tell popular keys from unpopular ones, and admission degrades to
+// chance: size is too small, or samples too large.
+type Health struct {
+	Saturation float64 // fraction of sketch counters at sketch.Max
+	Mean       float64 // average sketch counter value
+
+	// The doorkeeper is measured just before each reset, when it is
+	// fullest.  Both are zero until the first reset.
+	FillRatio         float64 // fraction of doorkeeper bits set
+	FalsePositiveRate float64 // fraction of never-inserted hashes the doorkeeper reported as seen
+}
+
+// probes is the number of hashes tested to measure the doorkeeper's false
+// positive rate
+const probes = 1024
+
+// Health reports the state of the cache's admission policy.  It takes time
+// proportional to the size of the frequency sketch.
+func (a *admission) Health() Health {
+	return Health{
+		Saturation:        a.c.Saturation(),
+		Mean:              a.c.Mean(),
+		FillRatio:         a.fill,
+		FalsePositiveRate: a.fpr,
+	}
+}
+
+// measure records the doorkeeper's fill ratio and false positive rate
+func (a *admission) measure() {
+	a.fill = a.bouncer.FillRatio()
+
+	// Random hashes are, with overwhelming probability, not ones which have
+	// been inserted, so any reported as seen are false positives.
+	var n int
+	for i := 0; i < probes; i++ {
+		if a.bouncer.Test(a.rand()) {
+			n++
+		}
+	}
+	a.fpr = float64(n) / probes
+}
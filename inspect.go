@@ -0,0 +1,33 @@
+package tinylfu
+
+// Inspection is a snapshot of a cache's internal state, for debugging.
+type Inspection[K comparable] struct {
+	Stats     Stats
+	Health    Health
+	Segments  map[Segment]int // number of items in each non-empty segment
+	FillRatio float64         // fraction of doorkeeper bits currently set
+	HotKeys   []HotKey[K]     // the hottest keys, if tracked with TrackTopK
+}
+
+// Inspect returns a snapshot of the cache's state, including up to hot of
+// its most frequently looked up keys.  It takes time proportional to the
+// cache's capacity, and doesn't modify the cache.
+func (t *T[K, V]) Inspect(hot int) Inspection[K] {
+	in := Inspection[K]{
+		Stats:     t.Stats(),
+		Health:    t.Health(),
+		Segments:  make(map[Segment]int),
+		FillRatio: t.bouncer.FillRatio(),
+		HotKeys:   t.TopK(hot),
+	}
+
+	for i := range t.items {
+		item := &t.items[i]
+		if item.listid == listFree || t.stale(item) || t.expired(item) {
+			continue
+		}
+		in.Segments[t.segmentOf(int32(i))]++
+	}
+
+	return in
+}
@@ -0,0 +1,127 @@
+// Package ilist implements doubly linked lists threaded by index through a
+// shared, preallocated array of links.
+//
+// The elements of the lists are the integers 0..n-1, typically indices into a
+// caller-owned slice of entries.  Each element may be on at most one list at a
+// time.  Because links are plain integers, the lists contain no pointers for
+// the garbage collector to scan and never allocate after construction.
+//
+// To iterate over list l:
+//
+//	for i := ls.Front(l); i != ilist.Nil; i = ls.Next(l, i) {
+//		// do something with entries[i]
+//	}
+package ilist
+
+// Nil is returned in place of an element when there is none.
+const Nil int32 = -1
+
+type link struct {
+	prev, next int32
+}
+
+// Lists is a fixed set of lists over the elements 0..n-1.
+type Lists struct {
+	// links[0:n] are the elements; links[n+l] is the sentinel for list l,
+	// so each list is a ring through its own sentinel.
+	links []link
+	lens  []int
+	n     int32
+}
+
+// New returns count empty lists over the elements 0..n-1.
+func New(n, count int) *Lists {
+	ls := &Lists{
+		links: make([]link, n+count),
+		lens:  make([]int, count),
+		n:     int32(n),
+	}
+	for l := 0; l < count; l++ {
+		r := ls.root(l)
+		ls.links[r] = link{r, r}
+	}
+	return ls
+}
+
+func (ls *Lists) root(l int) int32 { return ls.n + int32(l) }
+
+// Cap returns the number of elements.
+func (ls *Lists) Cap() int { return int(ls.n) }
+
+// Len returns the number of elements on list l.
+func (ls *Lists) Len(l int) int { return ls.lens[l] }
+
+// Front returns the first element of list l or Nil.
+func (ls *Lists) Front(l int) int32 { return ls.Next(l, ls.root(l)) }
+
+// Back returns the last element of list l or Nil.
+func (ls *Lists) Back(l int) int32 { return ls.Prev(l, ls.root(l)) }
+
+// Next returns the element after i on list l or Nil.
+func (ls *Lists) Next(l int, i int32) int32 {
+	if n := ls.links[i].next; n != ls.root(l) {
+		return n
+	}
+	return Nil
+}
+
+// Prev returns the element before i on list l or Nil.
+func (ls *Lists) Prev(l int, i int32) int32 {
+	if p := ls.links[i].prev; p != ls.root(l) {
+		return p
+	}
+	return Nil
+}
+
+// insert inserts i after at
+func (ls *Lists) insert(i, at int32) {
+	n := ls.links[at].next
+	ls.links[i] = link{prev: at, next: n}
+	ls.links[at].next = i
+	ls.links[n].prev = i
+}
+
+// unlink removes i from whichever list it is on
+func (ls *Lists) unlink(i int32) {
+	p, n := ls.links[i].prev, ls.links[i].next
+	ls.links[p].next = n
+	ls.links[n].prev = p
+}
+
+// PushFront inserts i at the front of list l.  i must not be on any list.
+func (ls *Lists) PushFront(l int, i int32) {
+	ls.insert(i, ls.root(l))
+	ls.lens[l]++
+}
+
+// PushBack inserts i at the back of list l.  i must not be on any list.
+func (ls *Lists) PushBack(l int, i int32) {
+	ls.insert(i, ls.links[ls.root(l)].prev)
+	ls.lens[l]++
+}
+
+// Remove removes i from list l.  i must be on list l.
+func (ls *Lists) Remove(l int, i int32) {
+	ls.unlink(i)
+	ls.lens[l]--
+}
+
+// MoveToFront moves i, which must be on list l, to the front of list l.
+func (ls *Lists) MoveToFront(l int, i int32) {
+	r := ls.root(l)
+	if ls.links[r].next == i {
+		return
+	}
+	ls.unlink(i)
+	ls.insert(i, r)
+}
+
+// MoveToBack moves i, which must be on list l, to the back of list l.
+func (ls *Lists) MoveToBack(l int, i int32) {
+	r := ls.root(l)
+	if ls.links[r].prev == i {
+		return
+	}
+	ls.unlink(i)
+	ls.insert(i, ls.links[r].prev)
+}
@@ -0,0 +1,101 @@
+package ilist
+
+import (
+	"slices"
+	"testing"
+)
+
+// elems returns the elements of list l, front to back
+func elems(ls *Lists, l int) []int32 {
+	var s []int32
+	for i := ls.Front(l); i != Nil; i = ls.Next(l, i) {
+		s = append(s, i)
+	}
+	return s
+}
+
+func check(t *testing.T, ls *Lists, l int, want ...int32) {
+	t.Helper()
+	if got := elems(ls, l); !slices.Equal(got, want) || ls.Len(l) != len(want) {
+		t.Errorf("list %d = %v (len %d), want %v", l, got, ls.Len(l), want)
+	}
+	var back []int32
+	for i := ls.Back(l); i != Nil; i = ls.Prev(l, i) {
+		back = append(back, i)
+	}
+	slices.Reverse(back)
+	if !slices.Equal(back, want) {
+		t.Errorf("list %d backwards = %v, want %v", l, back, want)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	ls := New(4, 2)
+	if ls.Cap() != 4 {
+		t.Errorf("ls.Cap()=%d, want 4", ls.Cap())
+	}
+	for l := 0; l < 2; l++ {
+		if f, b := ls.Front(l), ls.Back(l); f != Nil || b != Nil {
+			t.Errorf("empty list %d: Front()=%d Back()=%d, want Nil", l, f, b)
+		}
+		check(t, ls, l)
+	}
+}
+
+func TestPush(t *testing.T) {
+	ls := New(4, 2)
+
+	ls.PushFront(0, 1)
+	check(t, ls, 0, 1)
+	ls.PushBack(1, 2)
+	check(t, ls, 1, 2)
+
+	ls.PushFront(0, 0)
+	ls.PushBack(0, 3)
+	check(t, ls, 0, 0, 1, 3)
+	check(t, ls, 1, 2)
+}
+
+func TestRemove(t *testing.T) {
+	ls := New(4, 2)
+	ls.PushBack(0, 0)
+	ls.PushBack(0, 1)
+	ls.PushBack(0, 2)
+
+	ls.Remove(0, 1)
+	check(t, ls, 0, 0, 2)
+	ls.Remove(0, 0)
+	check(t, ls, 0, 2)
+	ls.Remove(0, 2)
+	check(t, ls, 0)
+	if b := ls.Back(0); b != Nil {
+		t.Errorf("ls.Back()=%d after removing everything, want Nil", b)
+	}
+
+	// removed elements can go on another list
+	ls.PushFront(1, 1)
+	check(t, ls, 1, 1)
+}
+
+func TestMove(t *testing.T) {
+	ls := New(4, 1)
+
+	ls.PushBack(0, 0)
+	ls.MoveToFront(0, 0)
+	check(t, ls, 0, 0)
+	ls.MoveToBack(0, 0)
+	check(t, ls, 0, 0)
+
+	ls.PushBack(0, 1)
+	ls.PushBack(0, 2)
+	ls.MoveToFront(0, 2)
+	check(t, ls, 0, 2, 0, 1)
+	ls.MoveToFront(0, 2)
+	check(t, ls, 0, 2, 0, 1)
+	ls.MoveToBack(0, 2)
+	check(t, ls, 0, 0, 1, 2)
+	ls.MoveToBack(0, 2)
+	check(t, ls, 0, 0, 1, 2)
+	ls.MoveToBack(0, 1)
+	check(t, ls, 0, 0, 2, 1)
+}
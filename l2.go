@@ -0,0 +1,82 @@
+package tinylfu
+
+// L2 is a second-level store for items evicted from the cache.  Its methods
+// are called with the cache's own synchronisation, if any.
+type L2[K comparable, V any] interface {
+	// Get returns the value stored for key.
+	Get(key K) (V, bool)
+	// Add stores val for key, replacing any previous value.
+	Add(key K, val V)
+	// Remove deletes any value stored for key.
+	Remove(key K)
+	// Range calls f for each stored key and value until f returns false.
+	// f doesn't modify the store.
+	Range(f func(key K, val V) bool)
+}
+
+// SpillTo sets a second-level store for the cache.  Items evicted from the
+// main cache or refused admission to it are added to l2, and lookups which
+// miss in the cache fall back to l2, moving any item found there back into
+// the cache's window.  Items with tags aren't spilled, as l2 can't track
+// their invalidation.
+//
+// A key is held by either the cache or l2, never both: operations on a key
+// held in l2 move it back into the cache first, and adding, removing or
+// negatively caching a key discards l2's copy.  RemoveIf also removes
+// matching items from l2.
+func SpillTo[K comparable, V any](l2 L2[K, V]) Option[K, V] {
+	return func(t *T[K, V]) { t.l2 = l2 }
+}
+
+// fetch looks for key in the second-level store, moving it back into the
+// cache if found
+func (t *T[K, V]) fetch(key K, keyh uint64, record bool) (V, bool) {
+	if t.l2 == nil {
+		return *new(V), false
+	}
+
+	i, ok := t.unspill(key, keyh)
+	if !ok {
+		return *new(V), false
+	}
+
+	if record {
+		t.sample(keyh)
+		if t.topk != nil {
+			t.topk.offer(key, t.c.Estimate(keyh))
+		}
+	}
+
+	t.stats.Hits++
+	t.stats.L2Hits++
+	if t.observer != nil {
+		t.observer.OnGet(key, true)
+	}
+
+	return t.items[i].value, true
+}
+
+// unspill moves key from the second-level store back into the cache's
+// window, returning its slot
+func (t *T[K, V]) unspill(key K, keyh uint64) (int32, bool) {
+	if t.l2 == nil {
+		return 0, false
+	}
+
+	v, ok := t.l2.Get(key)
+	if !ok {
+		return 0, false
+	}
+	t.l2.Remove(key)
+
+	// new items stay in the window until at least the next insert
+	t.insert(slruItem[K, V]{key: key, value: v, keyh: keyh})
+	return t.data[key], true
+}
+
+// spill adds an item leaving the cache to the second-level store
+func (t *T[K, V]) spill(item *slruItem[K, V]) {
+	if t.l2 != nil && len(item.tags) == 0 {
+		t.l2.Add(item.key, item.value)
+	}
+}
@@ -0,0 +1,193 @@
+package tinylfu
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// compactMin is the smallest log file size worth compacting
+const compactMin = 1 << 20
+
+// Log is a file-backed L2 store.  Values are appended to a log file and
+// located through an in-memory index; when more than half the file is taken
+// up by replaced or removed values, the live values are copied to a fresh
+// file.  The file is truncated when the Log is opened, so its contents don't
+// survive a restart.  It is not safe for concurrent access.
+//
+// Log's L2 methods can't return errors: a failed write drops the value and a
+// failed read reports a miss.  Err returns the first such error.
+type Log[K comparable, V any] struct {
+	path      string
+	f         *os.File
+	index     map[K]logEntry
+	size      int64 // bytes written to the file
+	live      int64 // bytes referenced by the index
+	marshal   func(V) ([]byte, error)
+	unmarshal func([]byte) (V, error)
+	err       error
+}
+
+type logEntry struct {
+	off int64
+	n   int
+}
+
+// OpenLog creates or truncates the file at path and returns a Log storing
+// values in it, encoded with marshal and decoded with unmarshal.
+func OpenLog[K comparable, V any](path string, marshal func(V) ([]byte, error), unmarshal func([]byte) (V, error)) (*Log[K, V], error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log[K, V]{
+		path:      path,
+		f:         f,
+		index:     make(map[K]logEntry),
+		marshal:   marshal,
+		unmarshal: unmarshal,
+	}, nil
+}
+
+// Get returns the value stored for key.
+func (l *Log[K, V]) Get(key K) (V, bool) {
+	e, ok := l.index[key]
+	if !ok {
+		return *new(V), false
+	}
+
+	b := make([]byte, e.n)
+	if _, err := l.f.ReadAt(b, e.off); err != nil {
+		l.fail(err)
+		return *new(V), false
+	}
+
+	v, err := l.unmarshal(b)
+	if err != nil {
+		l.fail(err)
+		return *new(V), false
+	}
+	return v, true
+}
+
+// Add appends val to the log and indexes it under key.
+func (l *Log[K, V]) Add(key K, val V) {
+	l.Remove(key)
+
+	b, err := l.marshal(val)
+	if err != nil {
+		l.fail(err)
+		return
+	}
+
+	if l.size+int64(len(b)) > compactMin && l.live < l.size/2 {
+		if err := l.compact(); err != nil {
+			l.fail(err)
+			return
+		}
+	}
+
+	if _, err := l.f.WriteAt(b, l.size); err != nil {
+		l.fail(err)
+		return
+	}
+
+	l.index[key] = logEntry{off: l.size, n: len(b)}
+	l.size += int64(len(b))
+	l.live += int64(len(b))
+}
+
+// Remove forgets any value stored for key.  The space it used is reclaimed
+// by a later compaction.
+func (l *Log[K, V]) Remove(key K) {
+	if e, ok := l.index[key]; ok {
+		l.live -= int64(e.n)
+		delete(l.index, key)
+	}
+}
+
+// Range calls f for each key and value in the log until f returns false.
+// Values which can't be read are skipped.
+func (l *Log[K, V]) Range(f func(key K, val V) bool) {
+	for k := range l.index {
+		v, ok := l.Get(k)
+		if ok && !f(k, v) {
+			return
+		}
+	}
+}
+
+// Len returns the number of values in the log.
+func (l *Log[K, V]) Len() int {
+	return len(l.index)
+}
+
+// Err returns the first error encountered reading or writing the log.
+func (l *Log[K, V]) Err() error {
+	return l.err
+}
+
+// Close closes the log file.  It doesn't remove it.
+func (l *Log[K, V]) Close() error {
+	return l.f.Close()
+}
+
+func (l *Log[K, V]) fail(err error) {
+	if l.err == nil {
+		l.err = err
+	}
+}
+
+// compact copies the live values to a new file, which replaces the old one
+func (l *Log[K, V]) compact() error {
+	fi, err := l.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp uses mode 0600; keep the log's original permissions
+	if err := f.Chmod(fi.Mode().Perm()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	index := make(map[K]logEntry, len(l.index))
+	var off int64
+	var b []byte
+	for k, e := range l.index {
+		if cap(b) < e.n {
+			b = make([]byte, e.n)
+		}
+		b = b[:e.n]
+		if _, err = l.f.ReadAt(b, e.off); err != nil {
+			break
+		}
+		if _, err = f.WriteAt(b, off); err != nil {
+			break
+		}
+		index[k] = logEntry{off: off, n: e.n}
+		off += int64(e.n)
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), l.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	l.f.Close()
+	l.f = f
+	l.index = index
+	l.size = off
+	l.live = off
+	return nil
+}
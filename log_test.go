@@ -0,0 +1,64 @@
+package tinylfu
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func openTestLog(t *testing.T) *Log[int, int] {
+	l, err := OpenLog[int, int](filepath.Join(t.TempDir(), "l2.log"),
+		func(v int) ([]byte, error) { return strconv.AppendInt(nil, int64(v), 10), nil },
+		func(b []byte) (int, error) { return strconv.Atoi(string(b)) },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func TestLog(t *testing.T) {
+	l := openTestLog(t)
+
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mode := fi.Mode()
+
+	// overwrite the same few keys until the log has been compacted
+	const keys, n = 100, compactMin / 2
+	for i := 0; i < n; i++ {
+		l.Add(i%keys, i)
+	}
+	l.Remove(0)
+
+	if err := l.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != keys-1 {
+		t.Errorf("l.Len()=%d, want %d", l.Len(), keys-1)
+	}
+	if _, ok := l.Get(0); ok {
+		t.Errorf("l.Get(0) found after removal")
+	}
+	for k := 1; k < keys; k++ {
+		want := (n-1-k)/keys*keys + k
+		if v, ok := l.Get(k); !ok || v != want {
+			t.Errorf("l.Get(%d)=%d,%v, want %d,true", k, v, ok, want)
+		}
+	}
+
+	fi, err = os.Stat(l.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != mode {
+		t.Errorf("log file mode %v after compaction, want %v", fi.Mode(), mode)
+	}
+	if fi.Size() > compactMin {
+		t.Errorf("log file is %d bytes, want it compacted to at most %d", fi.Size(), compactMin)
+	}
+}
@@ -1,62 +1,63 @@
 package tinylfu
 
-import "github.com/dgryski/go-tinylfu/internal/list"
-
 // Cache is an LRU cache.  It is not safe for concurrent access.
 type lruCache[K comparable, V any] struct {
-	data map[K]*list.Element[*slruItem[K, V]]
+	*slots[K, V]
+	list int
 	cap  int
-	ll   *list.List[*slruItem[K, V]]
 }
 
-func newLRU[K comparable, V any](cap int, data map[K]*list.Element[*slruItem[K, V]]) *lruCache[K, V] {
+func newLRU[K comparable, V any](list int, cap int, s *slots[K, V]) *lruCache[K, V] {
 	return &lruCache[K, V]{
-		data: data,
-		cap:  cap,
-		ll:   list.New[*slruItem[K, V]](),
+		slots: s,
+		list:  list,
+		cap:   cap,
 	}
 }
 
 // Get returns a value from the cache
-func (lru *lruCache[K, V]) get(v *list.Element[*slruItem[K, V]]) {
-	lru.ll.MoveToFront(v)
+func (lru *lruCache[K, V]) get(i int32) {
+	lru.ll.MoveToFront(lru.list, i)
 }
 
 // Set sets a value in the cache
 func (lru *lruCache[K, V]) add(newitem slruItem[K, V]) (oitem slruItem[K, V], evicted bool) {
-	if lru.ll.Len() < lru.cap {
-		lru.data[newitem.key] = lru.ll.PushFront(&newitem)
+	if lru.ll.Len(lru.list) < lru.cap {
+		lru.alloc(lru.list, newitem)
 		return slruItem[K, V]{}, false
 	}
 
 	// reuse the tail item
-	e := lru.ll.Back()
-	item := e.Value
-
-	delete(lru.data, item.key) // delete old key
+	return lru.replace(lru.list, newitem), true
+}
 
-	oitem = *item
-	*item = newitem
+func (lru *lruCache[K, V]) victim() *slruItem[K, V] {
+	if lru.Len() < lru.cap {
+		return nil
+	}
+	return &lru.items[lru.ll.Back(lru.list)]
+}
 
-	lru.data[item.key] = e // insert new key
-	lru.ll.MoveToFront(e)
+func (lru *lruCache[K, V]) peek() *slruItem[K, V] {
+	return lru.victim()
+}
 
-	return oitem, true
+func (lru *lruCache[K, V]) segment(i int32) Segment {
+	return SegmentMain
 }
 
 // Len returns the total number of items in the cache
 func (lru *lruCache[K, V]) Len() int {
-	return len(lru.data)
+	return lru.ll.Len(lru.list)
 }
 
 // Remove removes an item from the cache, returning the item and a boolean indicating if it was found
 func (lru *lruCache[K, V]) Remove(key K) (V, bool) {
-	v, ok := lru.data[key]
-	if !ok {
+	i, ok := lru.data[key]
+	if !ok || lru.items[i].listid != lru.list {
 		return *new(V), false
 	}
-	item := v.Value
-	lru.ll.Remove(v)
-	delete(lru.data, key)
-	return item.value, true
+	v := lru.items[i].value
+	lru.free(i)
+	return v, true
 }
@@ -0,0 +1,142 @@
+// Package metrics exports tinylfu cache statistics via expvar and in the
+// Prometheus text exposition format.
+//
+// Caches are registered under a name, which is used as the "cache" label of
+// every Prometheus sample and as the key of the expvar map:
+//
+//	reg := metrics.NewRegistry()
+//	reg.Register("users", cache)
+//	reg.Publish("tinylfu")
+//	http.Handle("/metrics", reg)
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	tinylfu "github.com/dgryski/go-tinylfu"
+)
+
+// Source is a cache reporting its statistics.
+//
+// Stats is called from whichever goroutine is serving the metrics, so it must
+// be safe to call concurrently with the cache's other operations.  Neither
+// *tinylfu.T nor *tinylfu.BytesCache is safe for concurrent access; wrap them
+// in a SourceFunc which takes the lock guarding the cache.
+type Source interface {
+	Stats() tinylfu.Stats
+}
+
+// SourceFunc adapts a function to the Source interface.
+type SourceFunc func() tinylfu.Stats
+
+// Stats calls f.
+func (f SourceFunc) Stats() tinylfu.Stats { return f() }
+
+// Registry is a set of named caches.  It is safe for concurrent use.
+type Registry struct {
+	mu     sync.Mutex
+	caches map[string]Source
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]Source)}
+}
+
+// Register adds the cache src under name, replacing any cache previously
+// registered with that name.
+func (r *Registry) Register(name string, src Source) {
+	r.mu.Lock()
+	r.caches[name] = src
+	r.mu.Unlock()
+}
+
+// Unregister removes the cache registered under name.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	delete(r.caches, name)
+	r.mu.Unlock()
+}
+
+type namedStats struct {
+	name  string
+	stats tinylfu.Stats
+}
+
+// snapshot returns the statistics of every registered cache, sorted by name
+func (r *Registry) snapshot() []namedStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := make([]namedStats, 0, len(r.caches))
+	for name, src := range r.caches {
+		s = append(s, namedStats{name, src.Stats()})
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].name < s[j].name })
+	return s
+}
+
+// Var returns an expvar.Var whose value is a map from cache name to its
+// statistics.
+func (r *Registry) Var() expvar.Var {
+	return expvar.Func(func() any {
+		m := make(map[string]tinylfu.Stats)
+		for _, s := range r.snapshot() {
+			m[s.name] = s.stats
+		}
+		return m
+	})
+}
+
+// Publish publishes the registry's Var under name.  Like expvar.Publish, it
+// panics if name is already in use.
+func (r *Registry) Publish(name string) {
+	expvar.Publish(name, r.Var())
+}
+
+var families = []struct {
+	name, typ, help string
+	value           func(tinylfu.Stats) float64
+}{
+	{"tinylfu_hits_total", "counter", "Lookups which found their key.", func(s tinylfu.Stats) float64 { return float64(s.Hits) }},
+	{"tinylfu_misses_total", "counter", "Lookups which did not find their key.", func(s tinylfu.Stats) float64 { return float64(s.Misses) }},
+	{"tinylfu_negative_hits_total", "counter", "Lookups which found a negative entry for their key.", func(s tinylfu.Stats) float64 { return float64(s.Negatives) }},
+	{"tinylfu_l2_hits_total", "counter", "Lookups served by the second-level store.", func(s tinylfu.Stats) float64 { return float64(s.L2Hits) }},
+	{"tinylfu_evictions_total", "counter", "Items evicted from the cache.", func(s tinylfu.Stats) float64 { return float64(s.Evictions) }},
+	{"tinylfu_rejections_total", "counter", "Items refused admission by the TinyLFU filter.", func(s tinylfu.Stats) float64 { return float64(s.Rejections) }},
+	{"tinylfu_size", "gauge", "Items currently in the cache.", func(s tinylfu.Stats) float64 { return float64(s.Size) }},
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes the statistics of every registered cache to w in
+// the Prometheus text exposition format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	stats := r.snapshot()
+
+	for _, f := range families {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ); err != nil {
+			return err
+		}
+		for _, s := range stats {
+			if _, err := fmt.Fprintf(w, "%s{cache=\"%s\"} %g\n", f.name, labelEscaper.Replace(s.name), f.value(s.stats)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ServeHTTP serves the registry's statistics in the Prometheus text
+// exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WritePrometheus(w)
+}
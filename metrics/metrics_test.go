@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tinylfu "github.com/dgryski/go-tinylfu"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	c := tinylfu.New[int, int](10, 100, func(k int) uint64 { return uint64(k) })
+	c.Add(1, 1)
+	c.Get(1)
+	c.Get(2)
+
+	r := NewRegistry()
+	r.Register("ints", c)
+	r.Register(`odd"name`, SourceFunc(func() tinylfu.Stats { return tinylfu.Stats{Evictions: 1234567} }))
+
+	var b strings.Builder
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		"# TYPE tinylfu_hits_total counter\n",
+		`tinylfu_hits_total{cache="ints"} 1` + "\n",
+		`tinylfu_misses_total{cache="ints"} 1` + "\n",
+		`tinylfu_size{cache="ints"} 1` + "\n",
+		`tinylfu_evictions_total{cache="odd\"name"} 1.234567e+06` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestVar(t *testing.T) {
+	r := NewRegistry()
+	r.Register("a", SourceFunc(func() tinylfu.Stats { return tinylfu.Stats{Hits: 3} }))
+
+	var m map[string]tinylfu.Stats
+	if err := json.Unmarshal([]byte(r.Var().String()), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["a"].Hits != 3 {
+		t.Errorf("Var()[a].Hits=%d, want 3", m["a"].Hits)
+	}
+}
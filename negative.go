@@ -0,0 +1,128 @@
+package tinylfu
+
+import "time"
+
+// Result is the outcome of a lookup with GetResult.
+type Result int
+
+const (
+	// ResultMiss means the cache holds nothing for the key.
+	ResultMiss Result = iota
+	// ResultHit means the cache holds a value for the key.
+	ResultHit
+	// ResultNegative means the key was recorded as not found with
+	// AddNegative, and the entry hasn't expired.
+	ResultNegative
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultMiss:
+		return "miss"
+	case ResultHit:
+		return "hit"
+	case ResultNegative:
+		return "negative"
+	}
+	return "unknown"
+}
+
+// NegativeCapacity allows up to n negative entries, held in addition to the
+// cache's size so they never displace real values.  The default is zero,
+// which disables negative caching.
+func NegativeCapacity[K comparable, V any](n int) Option[K, V] {
+	return func(t *T[K, V]) { t.negcap = n }
+}
+
+// expired reports whether item is a negative entry past its expiry
+func (t *T[K, V]) expired(item *slruItem[K, V]) bool {
+	return item.listid == listNegative && t.now() >= item.expiry
+}
+
+// nanotime returns the current time for negative entry expiry
+func nanotime() int64 {
+	return time.Now().UnixNano()
+}
+
+// AddNegative records that key has no value for the next ttl, replacing any
+// value cached for it.  Negative entries are kept in their own LRU list;
+// when it is full, a new key replaces the least recently used entry only if
+// TinyLFU admits it over that entry.  AddNegative returns false if the entry
+// wasn't stored.
+func (t *T[K, V]) AddNegative(key K, ttl time.Duration) bool {
+	if t.negcap == 0 {
+		return false
+	}
+
+	if t.l2 != nil {
+		t.l2.Remove(key)
+	}
+
+	if i, ok := t.lookup(key); ok {
+		v := t.items[i].value
+		t.slots.free(i)
+		t.remove(key, v)
+	}
+
+	expiry := t.now() + int64(ttl)
+
+	if i, ok := t.data[key]; ok {
+		// lookup discards expired entries, so this one is live
+		t.items[i].expiry = expiry
+		t.neg.get(i)
+		return true
+	}
+
+	newitem := slruItem[K, V]{key: key, keyh: t.hash(key), expiry: expiry}
+
+	if t.record(key, AccessAdd) {
+		t.sample(newitem.keyh)
+	}
+
+	// expired entries are replaced unconditionally
+	victim := t.neg.victim()
+	if victim != nil && victim.expiry > t.now() && !t.admit(newitem.keyh, victim.keyh) {
+		t.stats.Rejections++
+		return false
+	}
+
+	t.neg.add(newitem)
+	return true
+}
+
+// GetResult is like Get, but distinguishes keys with a negative entry from
+// keys the cache knows nothing about.
+func (t *T[K, V]) GetResult(key K) (V, Result) {
+	record := t.record(key, AccessGet)
+
+	if i, ok := t.lookup(key); ok {
+		return t.hit(i, record), ResultHit
+	}
+
+	// lookup discards expired entries, so any entry left is a live negative one
+	i, ok := t.data[key]
+	if !ok {
+		var keyh uint64
+		if record || t.l2 != nil {
+			keyh = t.hash(key)
+		}
+		if v, ok := t.fetch(key, keyh, record); ok {
+			return v, ResultHit
+		}
+		t.miss(key, keyh, record)
+		return *new(V), ResultMiss
+	}
+
+	item := &t.items[i]
+	if record {
+		t.sample(item.keyh)
+	}
+
+	t.stats.Negatives++
+	if t.observer != nil {
+		t.observer.OnGet(key, false)
+	}
+
+	t.neg.get(i)
+	return *new(V), ResultNegative
+}
@@ -0,0 +1,24 @@
+package tinylfu
+
+// Observer receives callbacks for cache events, for example to attach
+// tracing spans or structured logs.  Callbacks are made synchronously while
+// the cache is being updated and must not call back into the cache.
+type Observer[K comparable] interface {
+	// OnGet is called for every lookup.
+	OnGet(key K, hit bool)
+
+	// OnAdmit is called when an item leaving the window competes with the
+	// main cache's eviction victim.  The candidate is admitted, evicting the
+	// victim, if the doorkeeper has seen it before and candidateFreq is at
+	// least victimFreq; otherwise the candidate is evicted.
+	OnAdmit(candidate, victim K, candidateFreq, victimFreq int, admitted bool)
+
+	// OnReset is called when the sample window fills and the frequency
+	// sketch is aged.
+	OnReset()
+}
+
+// Observe sets an observer for cache events.
+func Observe[K comparable, V any](o Observer[K]) Option[K, V] {
+	return func(t *T[K, V]) { t.observer = o }
+}
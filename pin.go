@@ -0,0 +1,81 @@
+package tinylfu
+
+// PinnedCapacity allows up to n items to be pinned.  Pinned items are never
+// evicted and are held in addition to the cache's size.  The default is zero.
+func PinnedCapacity[K comparable, V any](n int) Option[K, V] {
+	return func(t *T[K, V]) { t.pincap = n }
+}
+
+// AddPinned adds key to the cache and pins it.  It returns false, leaving the
+// cache unchanged, if the key isn't already pinned and the pinned capacity
+// is exhausted.
+func (t *T[K, V]) AddPinned(key K, val V) bool {
+	if i, ok := t.lookup(key); ok {
+		if !t.pin(i) {
+			return false
+		}
+		t.update(i, val)
+		return true
+	}
+
+	if t.slots.ll.Len(listPinned) >= t.pincap {
+		return false
+	}
+
+	newitem := slruItem[K, V]{key: key, value: val, keyh: t.hash(key)}
+	if t.record(key, AccessAdd) {
+		t.sample(newitem.keyh)
+	}
+
+	if t.l2 != nil {
+		t.l2.Remove(key)
+	}
+
+	t.slots.alloc(listPinned, newitem)
+	return true
+}
+
+// Pin exempts a cached key from eviction.  It returns false if the key isn't
+// cached, or if it isn't already pinned and the pinned capacity is exhausted.
+func (t *T[K, V]) Pin(key K) bool {
+	i, ok := t.lookup(key)
+	if !ok {
+		return false
+	}
+	return t.pin(i)
+}
+
+// Unpin returns a pinned key to the cache's window, where it is subject to
+// eviction again.  It returns false if the key isn't pinned.
+func (t *T[K, V]) Unpin(key K) bool {
+	i, ok := t.lookup(key)
+	if !ok || t.items[i].listid != listPinned {
+		return false
+	}
+
+	item := t.items[i]
+	t.slots.free(i)
+	t.insert(item)
+	return true
+}
+
+// Pinned returns the number of pinned items.
+func (t *T[K, V]) Pinned() int {
+	return t.slots.ll.Len(listPinned)
+}
+
+// pin moves the item in slot i from its eviction list to the pinned list
+func (t *T[K, V]) pin(i int32) bool {
+	item := &t.items[i]
+	if item.listid == listPinned {
+		return true
+	}
+	if t.slots.ll.Len(listPinned) >= t.pincap {
+		return false
+	}
+
+	t.slots.ll.Remove(item.listid, i)
+	item.listid = listPinned
+	t.slots.ll.PushFront(listPinned, i)
+	return true
+}
@@ -0,0 +1,113 @@
+package tinylfu
+
+// Policy selects the eviction policy of the main cache region.  Whichever
+// policy is chosen, items only enter the main region once TinyLFU has
+// admitted them over the policy's eviction victim.
+type Policy int
+
+const (
+	// PolicySLRU is a segmented LRU with probation and protected segments,
+	// as in the W-TinyLFU paper.  This is the default.
+	PolicySLRU Policy = iota
+	// PolicyLRU is a single LRU list.
+	PolicyLRU
+	// PolicyClock is the CLOCK approximation of LRU, which avoids moving
+	// items on every hit.
+	PolicyClock
+	// PolicyS3FIFO is the S3-FIFO policy of a small probationary FIFO, a
+	// main FIFO with lazy promotion, and a ghost queue of recently evicted
+	// keys.
+	PolicyS3FIFO
+)
+
+// MainPolicy sets the eviction policy for the main cache region.
+func MainPolicy[K comparable, V any](p Policy) Option[K, V] {
+	return func(t *T[K, V]) { t.mainPolicy = p }
+}
+
+// policy is the eviction policy for the main cache region.  Items are
+// stored in the shared slots, on lists listOne, listOne+1, and so on; most
+// policies use listOne and listTwo, and an N-segment SLRU uses listOne+s for
+// segment s.
+type policy[K comparable, V any] interface {
+	// get updates the policy for a hit on the item in slot i
+	get(i int32)
+
+	// add adds newitem, returning the item evicted to make room, if any
+	add(newitem slruItem[K, V]) (oitem slruItem[K, V], evicted bool)
+
+	// victim returns the item the next add would evict, or nil if there
+	// is room.  It may reorder the policy's lists, but doesn't change which
+	// items are cached.
+	victim() *slruItem[K, V]
+
+	// peek returns the item victim would return, without changing the
+	// policy's state
+	peek() *slruItem[K, V]
+
+	// segment returns the segment holding the item in slot i
+	segment(i int32) Segment
+
+	Len() int
+}
+
+func newPolicy[K comparable, V any](p Policy, caps []int, s *slots[K, V]) policy[K, V] {
+	var cap int
+	for _, c := range caps {
+		cap += c
+	}
+
+	switch p {
+	case PolicyLRU:
+		return newLRU(listOne, cap, s)
+	case PolicyClock:
+		return newClock(cap, s)
+	case PolicyS3FIFO:
+		return newS3FIFO(cap, s)
+	}
+	return newSLRU(caps, s)
+}
+
+// SLRUSegments sets the number and relative sizes of the segments of the
+// main region's segmented LRU.  The first segment is the probationary one;
+// items are promoted a segment at a time on each hit.  The default is
+// SLRUSegments(1, 4), a probationary segment of 20% and a protected segment
+// of 80%; SLRUSegments(1, 1, 1, 1) is S4LRU.  Every share must be positive.
+// Each segment holds at least one item, so a main region smaller than the
+// number of segments uses only as many segments as it has room for.
+func SLRUSegments[K comparable, V any](shares ...int) Option[K, V] {
+	if len(shares) == 0 {
+		panic("tinylfu: no segment shares")
+	}
+	for _, s := range shares {
+		if s < 1 {
+			panic("tinylfu: bad segment share")
+		}
+	}
+
+	return func(t *T[K, V]) { t.segments = shares }
+}
+
+// splitSegments divides a main region of size items between segments in
+// proportion to shares, giving each segment at least one item
+func splitSegments(size int, shares []int) []int {
+	if size < len(shares) {
+		shares = shares[:size]
+	}
+
+	var total int
+	for _, s := range shares {
+		total += s
+	}
+
+	caps := make([]int, len(shares))
+	extra := size - len(shares)
+	rest := extra
+	for i, s := range shares[:len(shares)-1] {
+		caps[i] = 1 + extra*s/total
+		rest -= caps[i] - 1
+	}
+	caps[len(caps)-1] = 1 + rest
+
+	return caps
+}
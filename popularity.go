@@ -0,0 +1,66 @@
+package tinylfu
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgryski/go-tinylfu/bloom"
+	"github.com/dgryski/go-tinylfu/sketch"
+)
+
+// ErrPopularity is returned by MergePopularity for malformed data or data
+// from an incompatible cache.
+var ErrPopularity = errors.New("tinylfu: corrupt popularity data")
+
+// Popularity returns an encoding of the cache's frequency sketch and
+// doorkeeper, suitable for passing to MergePopularity on a peer.
+//
+// The encoding is only meaningful to caches created with the same size and
+// samples, and whose hash functions agree; in particular, a hash/maphash
+// seed must be shared between the peers.
+func (t *T[K, V]) Popularity() ([]byte, error) {
+	s, err := t.c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	d, err := t.bouncer.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, binary.MaxVarintLen64+len(s)+len(d))
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	b = append(b, s...)
+	b = append(b, d...)
+	return b, nil
+}
+
+// MergePopularity merges the frequency sketch and doorkeeper from a peer's
+// Popularity into the cache's own, so that admission decisions reflect the
+// popularity of keys across the peers.  Counters are added, saturating at
+// their maximum, and doorkeeper bits are combined.
+func (t *T[K, V]) MergePopularity(b []byte) error {
+	n, l := binary.Uvarint(b)
+	if l <= 0 || n > uint64(len(b)-l) {
+		return ErrPopularity
+	}
+	b = b[l:]
+
+	var s sketch.Sketch
+	if err := s.UnmarshalBinary(b[:n]); err != nil {
+		return ErrPopularity
+	}
+	var d bloom.Filter
+	if err := d.UnmarshalBinary(b[n:]); err != nil {
+		return ErrPopularity
+	}
+
+	// check both before modifying either
+	if !t.c.Compatible(&s) || !t.bouncer.Compatible(&d) {
+		return ErrPopularity
+	}
+
+	t.c.Merge(&s)
+	t.bouncer.Union(&d)
+	return nil
+}
@@ -0,0 +1,76 @@
+package tinylfu
+
+// OnRemove sets a function called with each item removed by Remove, RemoveIf
+// or Compute, or replaced by a negative entry with AddNegative.
+func OnRemove[K comparable, V any](f func(key K, old V)) Option[K, V] {
+	return func(t *T[K, V]) { t.remove = f }
+}
+
+// Remove removes key from the cache, returning its value and whether it was
+// present.  Any copy in the second-level store is discarded.
+func (t *T[K, V]) Remove(key K) (V, bool) {
+	if t.l2 != nil {
+		t.l2.Remove(key)
+	}
+
+	i, ok := t.lookup(key)
+	if !ok {
+		return *new(V), false
+	}
+
+	v := t.items[i].value
+	t.slots.free(i)
+	t.remove(key, v)
+	return v, true
+}
+
+// RemoveIf removes every item for which pred returns true, including pinned
+// items and items in the second-level store, and returns the number removed.
+// pred must not modify the cache.
+func (t *T[K, V]) RemoveIf(pred func(key K, val V) bool) int {
+	var n int
+	for j := range t.items {
+		item := &t.items[j]
+		if item.listid == listFree {
+			continue
+		}
+
+		i := int32(j)
+		if t.stale(item) {
+			t.slots.free(i)
+			continue
+		}
+		if item.listid == listNegative {
+			continue
+		}
+
+		if !pred(item.key, item.value) {
+			continue
+		}
+
+		key, v := item.key, item.value
+		t.slots.free(i)
+		t.remove(key, v)
+		n++
+	}
+
+	if t.l2 != nil {
+		var keys []K
+		var vals []V
+		t.l2.Range(func(k K, v V) bool {
+			if pred(k, v) {
+				keys = append(keys, k)
+				vals = append(vals, v)
+			}
+			return true
+		})
+
+		for j, k := range keys {
+			t.l2.Remove(k)
+			t.remove(k, vals[j])
+		}
+		n += len(keys)
+	}
+
+	return n
+}
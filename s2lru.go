@@ -1,126 +1,125 @@
 package tinylfu
 
-import "github.com/dgryski/go-tinylfu/internal/list"
-
 type slruItem[K comparable, V any] struct {
 	listid int
 	key    K
 	value  V
 	keyh   uint64
+	freq   uint8 // hit count, for policies which track it
+	tags   []tagRef
+	expiry int64 // for negative entries, in nanoseconds since the epoch
 }
 
-// Cache is an LRU cache.  It is not safe for concurrent access.
+// Cache is a segmented LRU cache.  New items enter the probationary
+// segment, and each hit promotes an item one segment up, demoting the tail of
+// a full segment into the one below.  It is not safe for concurrent access.
 type slruCache[K comparable, V any] struct {
-	data           map[K]*list.Element[*slruItem[K, V]]
-	onecap, twocap int
-	one, two       *list.List[*slruItem[K, V]]
+	*slots[K, V]
+	caps []int // capacity of each segment, the list for segment s is listOne+s
+	cap  int   // total capacity
 }
 
-func newSLRU[K comparable, V any](onecap, twocap int, data map[K]*list.Element[*slruItem[K, V]]) *slruCache[K, V] {
+func newSLRU[K comparable, V any](caps []int, s *slots[K, V]) *slruCache[K, V] {
+	var cap int
+	for _, c := range caps {
+		cap += c
+	}
+
 	return &slruCache[K, V]{
-		data:   data,
-		onecap: onecap,
-		one:    list.New[*slruItem[K, V]](),
-		twocap: twocap,
-		two:    list.New[*slruItem[K, V]](),
+		slots: s,
+		caps:  caps,
+		cap:   cap,
 	}
 }
 
 // get updates the cache data structures for a get
-func (slru *slruCache[K, V]) get(v *list.Element[*slruItem[K, V]]) {
-	item := v.Value
-
-	// already on list two?
-	if item.listid == 2 {
-		slru.two.MoveToFront(v)
+func (slru *slruCache[K, V]) get(i int32) {
+	item := &slru.items[i]
+	l := item.listid
+	next := l + 1
+
+	// already on the top list, or tiny caches with no room above
+	if next-listOne == len(slru.caps) || slru.caps[next-listOne] == 0 {
+		slru.ll.MoveToFront(l, i)
 		return
 	}
 
-	// must be list one
-
 	// is there space on the next list?
-	if slru.two.Len() < slru.twocap {
+	if slru.ll.Len(next) < slru.caps[next-listOne] {
 		// just do the remove/add
-		slru.one.Remove(v)
-		item.listid = 2
-		slru.data[item.key] = slru.two.PushFront(item)
+		slru.ll.Remove(l, i)
+		item.listid = next
+		slru.ll.PushFront(next, i)
 		return
 	}
 
-	back := slru.two.Back()
-	bitem := back.Value
+	// swap the item with the tail of the next list
+	b := slru.ll.Back(next)
+	slru.ll.Remove(next, b)
+	slru.ll.Remove(l, i)
 
-	// swap the key/values
-	*bitem, *item = *item, *bitem
+	slru.items[b].listid = l
+	item.listid = next
 
-	bitem.listid = 2
-	item.listid = 1
-
-	// update pointers in the map
-	slru.data[item.key] = v
-	slru.data[bitem.key] = back
-
-	// move the elements to the front of their lists
-	slru.one.MoveToFront(v)
-	slru.two.MoveToFront(back)
+	// move the items to the front of their new lists
+	slru.ll.PushFront(l, b)
+	slru.ll.PushFront(next, i)
 }
 
 // add adds a value to the cache
 func (slru *slruCache[K, V]) add(newitem slruItem[K, V]) (oitem slruItem[K, V], evicted bool) {
 
-	newitem.listid = 1
-
-	if slru.one.Len() < slru.onecap || (slru.Len() < slru.onecap+slru.twocap) {
-		slru.data[newitem.key] = slru.one.PushFront(&newitem)
+	if slru.ll.Len(listOne) < slru.caps[0] || (slru.Len() < slru.cap) {
+		slru.alloc(listOne, newitem)
 		return
 	}
 
 	// reuse the tail item
-	e := slru.one.Back()
-	item := e.Value
-
-	delete(slru.data, item.key) // delete old key
-
-	oitem = *item
-	*item = newitem
-
-	slru.data[item.key] = e // insert new key
-	slru.one.MoveToFront(e)
-	return oitem, true
+	return slru.replace(listOne, newitem), true
 }
 
 func (slru *slruCache[K, V]) victim() *slruItem[K, V] {
 
-	if slru.Len() < slru.onecap+slru.twocap {
+	if slru.Len() < slru.cap {
 		return nil
 	}
 
-	v := slru.one.Back()
+	return &slru.items[slru.ll.Back(listOne)]
+}
 
-	return v.Value
+func (slru *slruCache[K, V]) peek() *slruItem[K, V] {
+	return slru.victim()
+}
+
+func (slru *slruCache[K, V]) segment(i int32) Segment {
+	if slru.items[i].listid == listOne {
+		return SegmentProbation
+	}
+	return SegmentProtected
 }
 
 // Len returns the total number of items in the cache
 func (slru *slruCache[K, V]) Len() int {
-	return slru.one.Len() + slru.two.Len()
+	var n int
+	for s := range slru.caps {
+		n += slru.ll.Len(listOne + s)
+	}
+	return n
 }
 
 // Remove removes an item from the cache, returning the item and a boolean indicating if it was found
 func (slru *slruCache[K, V]) Remove(key K) (V, bool) {
-	v, ok := slru.data[key]
+	i, ok := slru.data[key]
 	if !ok {
 		return *new(V), false
 	}
 
-	item := v.Value
-
-	if item.listid == 2 {
-		slru.two.Remove(v)
-	} else {
-		slru.one.Remove(v)
+	item := &slru.items[i]
+	if item.listid < listOne || item.listid >= listOne+len(slru.caps) {
+		return *new(V), false
 	}
 
-	delete(slru.data, key)
-
-	return item.value, true
+	v := item.value
+	slru.free(i)
+	return v, true
 }
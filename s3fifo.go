@@ -0,0 +1,192 @@
+package tinylfu
+
+import "github.com/dgryski/go-tinylfu/internal/ilist"
+
+// s3fifoCache is an S3-FIFO cache.  New items enter a small FIFO queue, and
+// are moved to the main FIFO queue if they are hit before reaching its end.
+// Items at the end of the main queue are reinserted if they've been hit
+// since they were last considered.  The hashes of items evicted from the
+// small queue are remembered in a ghost queue, and items returning while
+// still in the ghost queue go straight to the main queue.
+type s3fifoCache[K comparable, V any] struct {
+	*slots[K, V]
+	smallcap, cap int
+	ghost         ghostQueue
+}
+
+// maximum hit count tracked for an item
+const s3fifoMaxFreq = 3
+
+func newS3FIFO[K comparable, V any](cap int, s *slots[K, V]) *s3fifoCache[K, V] {
+	smallcap := cap / 10
+	if smallcap < 1 {
+		smallcap = 1
+	}
+
+	return &s3fifoCache[K, V]{
+		slots:    s,
+		smallcap: smallcap,
+		cap:      cap,
+		ghost:    newGhostQueue(cap - smallcap),
+	}
+}
+
+// get records a hit on the item in slot i
+func (c *s3fifoCache[K, V]) get(i int32) {
+	if item := &c.items[i]; item.freq < s3fifoMaxFreq {
+		item.freq++
+	}
+}
+
+// add adds a value to the cache
+func (c *s3fifoCache[K, V]) add(newitem slruItem[K, V]) (oitem slruItem[K, V], evicted bool) {
+	newitem.freq = 0
+
+	l := listOne
+	if c.ghost.remove(newitem.keyh) {
+		l = listTwo
+	}
+
+	if c.Len() < c.cap {
+		c.alloc(l, newitem)
+		return slruItem[K, V]{}, false
+	}
+
+	v := c.evict()
+	oitem = c.items[v]
+	if oitem.listid == listOne {
+		c.ghost.add(oitem.keyh)
+	}
+	c.free(v)
+
+	c.alloc(l, newitem)
+	return oitem, true
+}
+
+func (c *s3fifoCache[K, V]) victim() *slruItem[K, V] {
+	if c.Len() < c.cap {
+		return nil
+	}
+	return &c.items[c.evict()]
+}
+
+// evict moves items between and within the queues until the item at the
+// end of one of them is due for eviction, and returns its slot
+func (c *s3fifoCache[K, V]) evict() int32 {
+	for {
+		if c.ll.Len(listOne) >= c.smallcap || c.ll.Len(listTwo) == 0 {
+			b := c.ll.Back(listOne)
+			item := &c.items[b]
+			if item.freq == 0 {
+				return b
+			}
+
+			// promote to the main queue
+			c.ll.Remove(listOne, b)
+			item.listid = listTwo
+			item.freq = 0
+			c.ll.PushFront(listTwo, b)
+			continue
+		}
+
+		b := c.ll.Back(listTwo)
+		item := &c.items[b]
+		if item.freq == 0 {
+			return b
+		}
+
+		item.freq--
+		c.ll.MoveToFront(listTwo, b)
+	}
+}
+
+// peek works out which item evict would return, without moving anything
+func (c *s3fifoCache[K, V]) peek() *slruItem[K, V] {
+	if c.Len() < c.cap {
+		return nil
+	}
+
+	// Hit items at the end of the small queue are promoted until an
+	// unhit one is found or the queue shrinks below its capacity.
+	small, main := c.ll.Len(listOne), c.ll.Len(listTwo)
+	promoted := ilist.Nil
+	for b := c.ll.Back(listOne); b != ilist.Nil && (small >= c.smallcap || main == 0); b = c.ll.Prev(listOne, b) {
+		if c.items[b].freq == 0 {
+			return &c.items[b]
+		}
+		if promoted == ilist.Nil {
+			promoted = b
+		}
+		small--
+		main++
+	}
+
+	// Each pass over the main queue decrements the hit counts, so the
+	// victim is the first item from the end with the lowest count, unless
+	// every count is positive and an item was promoted: promoted items
+	// join the queue with no hits and are reached after a single pass.
+	v := ilist.Nil
+	for b := c.ll.Back(listTwo); b != ilist.Nil; b = c.ll.Prev(listTwo, b) {
+		if v == ilist.Nil || c.items[b].freq < c.items[v].freq {
+			v = b
+		}
+	}
+	if v == ilist.Nil || c.items[v].freq > 0 && promoted != ilist.Nil {
+		v = promoted
+	}
+	return &c.items[v]
+}
+
+func (c *s3fifoCache[K, V]) segment(i int32) Segment {
+	if c.items[i].listid == listOne {
+		return SegmentProbation
+	}
+	return SegmentMain
+}
+
+// Len returns the total number of items in the cache
+func (c *s3fifoCache[K, V]) Len() int {
+	return c.ll.Len(listOne) + c.ll.Len(listTwo)
+}
+
+// ghostQueue is a bounded FIFO of key hashes
+type ghostQueue struct {
+	ring  []uint64
+	head  int
+	n     int
+	index map[uint64]int // hash to the position of its newest copy in the ring
+}
+
+func newGhostQueue(cap int) ghostQueue {
+	if cap < 1 {
+		cap = 1
+	}
+	return ghostQueue{
+		ring:  make([]uint64, cap),
+		index: make(map[uint64]int, cap),
+	}
+}
+
+// add appends keyh, forgetting the oldest hash if the queue is full
+func (g *ghostQueue) add(keyh uint64) {
+	if g.n == len(g.ring) {
+		if old := g.ring[g.head]; g.index[old] == g.head {
+			delete(g.index, old)
+		}
+	} else {
+		g.n++
+	}
+	g.ring[g.head] = keyh
+	g.index[keyh] = g.head
+	g.head = (g.head + 1) % len(g.ring)
+}
+
+// remove reports whether keyh is in the queue, forgetting it if so.  Its
+// position in the ring is reclaimed when the ring wraps around.
+func (g *ghostQueue) remove(keyh uint64) bool {
+	if _, ok := g.index[keyh]; !ok {
+		return false
+	}
+	delete(g.index, keyh)
+	return true
+}
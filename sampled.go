@@ -0,0 +1,112 @@
+package tinylfu
+
+// Sampled is a TinyLFU cache using sampled eviction instead of eviction
+// lists.  Items are kept in a flat array; when the cache is full, the victim
+// is the least frequently used of a few randomly chosen items, and a new key
+// replaces it only if TinyLFU admits it over the victim.  This avoids the
+// per-item list links and per-hit list updates of T, at the cost of a less
+// accurate choice of victim, which suits very large caches.  It is not safe
+// for concurrent access.
+type Sampled[K comparable, V any] struct {
+	admission
+
+	cap   int
+	k     int // number of items sampled for each eviction
+	data  map[K]int32
+	items []sampledItem[K, V]
+	hash  func(K) uint64
+
+	stats Stats
+}
+
+type sampledItem[K comparable, V any] struct {
+	key   K
+	value V
+	keyh  uint64
+}
+
+// NewSampled returns a cache holding up to size items, aging its frequency
+// sketch every samples accesses and choosing each victim from k random items.
+func NewSampled[K comparable, V any](size, samples, k int, hash func(K) uint64) *Sampled[K, V] {
+	if k < 1 {
+		k = 1
+	}
+
+	return &Sampled[K, V]{
+		admission: newAdmission(size, samples),
+
+		cap:   size,
+		k:     k,
+		data:  make(map[K]int32, size),
+		items: make([]sampledItem[K, V], 0, size),
+		hash:  hash,
+	}
+}
+
+func (s *Sampled[K, V]) Get(key K) (V, bool) {
+	i, ok := s.data[key]
+	if !ok {
+		s.count(s.hash(key))
+		s.stats.Misses++
+		return *new(V), false
+	}
+
+	item := &s.items[i]
+	s.count(item.keyh)
+	s.stats.Hits++
+	return item.value, true
+}
+
+func (s *Sampled[K, V]) Add(key K, val V) {
+	if i, ok := s.data[key]; ok {
+		item := &s.items[i]
+		item.value = val
+		s.c.Add(item.keyh)
+		return
+	}
+
+	newitem := sampledItem[K, V]{key, val, s.hash(key)}
+
+	if len(s.items) < s.cap {
+		s.data[key] = int32(len(s.items))
+		s.items = append(s.items, newitem)
+		return
+	}
+
+	v := s.victim()
+	if !s.admit(newitem.keyh, s.items[v].keyh) {
+		s.stats.Rejections++
+		s.stats.Evictions++
+		return
+	}
+
+	s.stats.Evictions++
+	delete(s.data, s.items[v].key)
+	s.items[v] = newitem
+	s.data[key] = v
+}
+
+// victim returns the least frequently used of k randomly chosen items
+func (s *Sampled[K, V]) victim() int32 {
+	var v int32
+	var vcount byte = 255
+	for j := 0; j < s.k; j++ {
+		i := int32(s.rand() % uint64(len(s.items)))
+		if c := s.c.Estimate(s.items[i].keyh); c < vcount {
+			v, vcount = i, c
+		}
+	}
+	return v
+}
+
+// Len returns the number of items in the cache.
+func (s *Sampled[K, V]) Len() int {
+	return len(s.items)
+}
+
+// Stats returns the cache's counters.
+func (s *Sampled[K, V]) Stats() Stats {
+	st := s.stats
+	st.Size = s.Len()
+	return st
+}
@@ -0,0 +1,78 @@
+package tinylfu
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSampled(t *testing.T) {
+	const size = 100
+	c := NewSampled[int, int](size, 10*size, 5, func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 })
+
+	c.Add(1, 1)
+	c.Add(1, 2)
+	if v, ok := c.Get(1); !ok || v != 2 {
+		t.Errorf("c.Get(1)=%d,%v, want 2,true", v, ok)
+	}
+
+	for i := 0; i < 10*size; i++ {
+		if v, ok := c.Get(i); ok && v != i && i != 1 {
+			t.Fatalf("c.Get(%d)=%d", i, v)
+		}
+		c.Add(i, i)
+		if c.Len() > size {
+			t.Fatalf("c.Len()=%d, want <= %d", c.Len(), size)
+		}
+	}
+
+	for k, i := range c.items {
+		if c.data[i.key] != int32(k) {
+			t.Fatalf("data[%d]=%d, want %d", i.key, c.data[i.key], k)
+		}
+	}
+}
+
+type cache[K comparable, V any] interface {
+	Get(K) (V, bool)
+	Add(K, V)
+}
+
+// hitRatio runs a Zipf-distributed cache-aside workload against c
+func hitRatio(c cache[uint64, uint64], keys, n int) float64 {
+	z := rand.NewZipf(rand.New(rand.NewSource(1)), 1.01, 1, uint64(keys))
+
+	var hits int
+	for i := 0; i < n; i++ {
+		k := z.Uint64()
+		if _, ok := c.Get(k); ok {
+			hits++
+			continue
+		}
+		c.Add(k, k)
+	}
+	return float64(hits) / float64(n)
+}
+
+func BenchmarkHitRatio(b *testing.B) {
+	const size = 10000
+	hash := func(k uint64) uint64 { return k * 0x9e3779b97f4a7c15 }
+
+	caches := []struct {
+		name string
+		new  func() cache[uint64, uint64]
+	}{
+		{"lists", func() cache[uint64, uint64] { return New[uint64, uint64](size, 10*size, hash) }},
+		{"sampled-5", func() cache[uint64, uint64] { return NewSampled[uint64, uint64](size, 10*size, 5, hash) }},
+		{"sampled-16", func() cache[uint64, uint64] { return NewSampled[uint64, uint64](size, 10*size, 16, hash) }},
+	}
+
+	for _, c := range caches {
+		b.Run(c.name, func(b *testing.B) {
+			var ratio float64
+			for i := 0; i < b.N; i++ {
+				ratio = hitRatio(c.new(), 100*size, 20*size)
+			}
+			b.ReportMetric(100*ratio, "hit%")
+		})
+	}
+}
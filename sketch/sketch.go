@@ -0,0 +1,240 @@
+// Package sketch implements a compact count-min sketch with 4-bit counters,
+// as used by TinyLFU to estimate the recent popularity of keys.
+package sketch
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Sketch is a small count-min sketch implementation with 4-bit counters.
+// Counters saturate at 15 and are halved by Reset, so the sketch tracks
+// recent rather than all-time popularity.  It is not safe for concurrent
+// access.
+type Sketch struct {
+	s    [depth]nvec
+	mask uint32
+}
+
+const depth = 4
+
+// Max is the largest value a counter can hold.
+const Max = 15
+
+// ErrMismatch is returned when merging sketches of different widths.
+var ErrMismatch = errors.New("sketch: mismatched widths")
+
+// ErrCorrupt is returned when unmarshalling malformed data.
+var ErrCorrupt = errors.New("sketch: corrupt data")
+
+// New returns a sketch sized to track w distinct keys.
+func New(w int) *Sketch {
+	if w < 1 {
+		panic("sketch: bad width")
+	}
+
+	// use 4 counters per item per level, for a total of 16 counters or 8 bytes per item, matching the TinyLFU paper.
+	w32 := nextPowerOfTwo(uint32(w) * 4)
+	return newWidth(w32)
+}
+
+// newWidth returns a sketch with w32 counters per level
+func newWidth(w32 uint32) *Sketch {
+	c := Sketch{
+		mask: w32 - 1,
+	}
+
+	for i := 0; i < depth; i++ {
+		c.s[i] = newNvec(int(w32))
+	}
+
+	return &c
+}
+
+// Add records an occurrence of the key with hash keyh.
+func (c *Sketch) Add(keyh uint64) {
+	// The loop unrolling prevents this function from being inlined, but it still results in a slight overall speedup.
+	c.s[3].inc(c.counterOffset(keyh, 3))
+	c.s[2].inc(c.counterOffset(keyh, 2))
+	c.s[1].inc(c.counterOffset(keyh, 1))
+	c.s[0].inc(c.counterOffset(keyh, 0))
+}
+
+func (c *Sketch) counterOffset(keyh uint64, level int) uint32 {
+	// counterOffset gets inlined and the compiler removes the duplicated computations of h1 and h2, so there is no
+	// benefit to accepting h1 and h2 as arguments.
+	h1, h2 := uint32(keyh), uint32(keyh>>32)
+	return (h1 + uint32(level)*h2) & c.mask
+}
+
+// Estimate returns the estimated count for the key with hash keyh.
+func (c *Sketch) Estimate(keyh uint64) byte {
+	var min byte = 255
+	bmin := func(v, min byte) byte {
+		// bmin gets inlined and the else branch gets optimized away
+		if v < min {
+			return v
+		} else {
+			return min
+		}
+	}
+	min = bmin(c.s[3].get(c.counterOffset(keyh, 3)), min)
+	min = bmin(c.s[2].get(c.counterOffset(keyh, 2)), min)
+	min = bmin(c.s[1].get(c.counterOffset(keyh, 1)), min)
+	min = bmin(c.s[0].get(c.counterOffset(keyh, 0)), min)
+	return min
+}
+
+// Reset halves every counter, aging the sketch.
+func (c *Sketch) Reset() {
+	// There is no point in unrolling this loop, the cost is dominated by nvec.reset, which is O(n)
+	for _, n := range c.s {
+		n.reset()
+	}
+}
+
+// Saturation returns the fraction of counters at Max.  A sketch with many
+// saturated counters can't tell popular keys apart, which suggests it is too
+// small or aged too rarely.
+func (c *Sketch) Saturation() float64 {
+	var n int
+	for _, v := range c.s {
+		n += v.saturated()
+	}
+	return float64(n) / float64(depth*(c.mask+1))
+}
+
+// Mean returns the average counter value.
+func (c *Sketch) Mean() float64 {
+	var sum int
+	for _, v := range c.s {
+		sum += v.sum()
+	}
+	return float64(sum) / float64(depth*(c.mask+1))
+}
+
+// Compatible reports whether o has the same width as c, and so can be merged
+// into it.
+func (c *Sketch) Compatible(o *Sketch) bool {
+	return c.mask == o.mask
+}
+
+// Merge adds the counts from o into c, saturating at Max.  The sketches must
+// have been created with the same width.
+func (c *Sketch) Merge(o *Sketch) error {
+	if !c.Compatible(o) {
+		return ErrMismatch
+	}
+
+	for i := range c.s {
+		c.s[i].merge(o.s[i])
+	}
+	return nil
+}
+
+// MarshalBinary encodes the sketch.
+func (c *Sketch) MarshalBinary() ([]byte, error) {
+	w := len(c.s[0])
+	b := make([]byte, 4, 4+depth*w)
+	binary.LittleEndian.PutUint32(b, c.mask+1)
+	for _, n := range c.s {
+		b = append(b, n...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces c with the sketch encoded in b.
+func (c *Sketch) UnmarshalBinary(b []byte) error {
+	if len(b) < 4 {
+		return ErrCorrupt
+	}
+
+	w32 := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	if w32 < 2 || w32&(w32-1) != 0 || uint64(len(b)) != depth*uint64(w32/2) {
+		return ErrCorrupt
+	}
+
+	*c = *newWidth(w32)
+	for i := range c.s {
+		b = b[copy(c.s[i], b):]
+	}
+	return nil
+}
+
+// nybble vector
+type nvec []byte
+
+func newNvec(w int) nvec {
+	return make(nvec, w/2)
+}
+
+func (n nvec) get(i uint32) byte {
+	// Ugly, but as a single expression so the compiler will inline it :/
+	return byte(n[i/2]>>((i&1)*4)) & 0x0f
+}
+
+func (n nvec) inc(i uint32) {
+	idx := i / 2
+	shift := (i & 1) * 4
+	v := (n[idx] >> shift) & 0x0f
+	if v < 15 {
+		n[idx] += 1 << shift
+	}
+}
+
+func (n nvec) reset() {
+	for i := range n {
+		n[i] = (n[i] >> 1) & 0x77
+	}
+}
+
+// saturated returns the number of counters at 15
+func (n nvec) saturated() int {
+	var c int
+	for _, b := range n {
+		if b&0x0f == 0x0f {
+			c++
+		}
+		if b&0xf0 == 0xf0 {
+			c++
+		}
+	}
+	return c
+}
+
+// sum returns the total of the counters
+func (n nvec) sum() int {
+	var s int
+	for _, b := range n {
+		s += int(b&0x0f) + int(b>>4)
+	}
+	return s
+}
+
+// merge adds the counters of o to n, saturating at 15
+func (n nvec) merge(o nvec) {
+	for i := range n {
+		lo := n[i]&0x0f + o[i]&0x0f
+		hi := n[i]>>4 + o[i]>>4
+		if lo > 15 {
+			lo = 15
+		}
+		if hi > 15 {
+			hi = 15
+		}
+		n[i] = hi<<4 | lo
+	}
+}
+
+// return the integer >= i which is a power of two
+func nextPowerOfTwo(i uint32) uint32 {
+	n := i - 1
+	n |= n >> 1
+	n |= n >> 2
+	n |= n >> 4
+	n |= n >> 8
+	n |= n >> 16
+	n++
+	return n
+}
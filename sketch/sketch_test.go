@@ -0,0 +1,181 @@
+package sketch
+
+import (
+	"testing"
+)
+
+func TestNvec(t *testing.T) {
+
+	n := newNvec(8)
+
+	n.inc(0)
+	if n[0] != 0x01 {
+		t.Errorf("n[0]=0x%02x, want 0x01: (n=% 02x)", n[0], n)
+	}
+	if w := n.get(0); w != 1 {
+		t.Errorf("n.get(0)=%d, want 1", w)
+	}
+	if w := n.get(1); w != 0 {
+		t.Errorf("n.get(1)=%d, want 0", w)
+	}
+
+	n.inc(1)
+	if n[0] != 0x11 {
+		t.Errorf("n[0]=0x%02x, want 0x11: (n=% 02x)", n[0], n)
+	}
+	if w := n.get(0); w != 1 {
+		t.Errorf("n.get(0)=%d, want 1", w)
+	}
+	if w := n.get(1); w != 1 {
+		t.Errorf("n.get(1)=%d, want 1", w)
+	}
+
+	for i := 0; i < 14; i++ {
+		n.inc(1)
+	}
+	if n[0] != 0xf1 {
+		t.Errorf("n[1]=0x%02x, want 0xf1: (n=% 02x)", n[0], n)
+	}
+	if w := n.get(1); w != 15 {
+		t.Errorf("n.get(1)=%d, want 15", w)
+	}
+	if w := n.get(0); w != 1 {
+		t.Errorf("n.get(0)=%d, want 1", w)
+	}
+
+	// ensure clamped
+	for i := 0; i < 3; i++ {
+		n.inc(1)
+		if n[0] != 0xf1 {
+			t.Errorf("n[0]=0x%02x, want 0xf1: (n=% 02x)", n[0], n)
+		}
+	}
+
+	n.reset()
+
+	if n[0] != 0x70 {
+		t.Errorf("n[0]=0x%02x, want 0x70 (n=% 02x)", n[0], n)
+	}
+}
+
+func TestSketch(t *testing.T) {
+
+	cm := New(32)
+
+	hash := uint64(0x0ddc0ffeebadf00d)
+
+	cm.Add(hash)
+	cm.Add(hash)
+
+	if got := cm.Estimate(hash); got != 2 {
+		t.Errorf("cm.Estimate(%x)=%d, want 2\n", hash, got)
+	}
+}
+
+func TestSaturation(t *testing.T) {
+	c := New(16)
+	if s := c.Saturation(); s != 0 {
+		t.Errorf("c.Saturation()=%v for an empty sketch, want 0", s)
+	}
+
+	for i := 0; i < Max; i++ {
+		c.Add(0x0000000100000000)
+	}
+	// the key saturates one counter in each level
+	if s, want := c.Saturation(), 1/float64(c.mask+1); s != want {
+		t.Errorf("c.Saturation()=%v, want %v", s, want)
+	}
+
+	c.Reset()
+	if s := c.Saturation(); s != 0 {
+		t.Errorf("c.Saturation()=%v after Reset, want 0", s)
+	}
+}
+
+func TestMean(t *testing.T) {
+	c := New(16)
+	for i := 0; i < 3; i++ {
+		c.Add(0x0000000100000000)
+	}
+	if m, want := c.Mean(), 3/float64(c.mask+1); m != want {
+		t.Errorf("c.Mean()=%v, want %v", m, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(32), New(32)
+
+	h1, h2 := uint64(0x0ddc0ffeebadf00d), uint64(0x1234567887654321)
+
+	for i := 0; i < 10; i++ {
+		a.Add(h1)
+		b.Add(h1)
+	}
+	b.Add(h2)
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("a.Merge(b)=%v", err)
+	}
+
+	if got := a.Estimate(h1); got != Max {
+		t.Errorf("a.Estimate(h1)=%d, want %d", got, Max)
+	}
+	if got := a.Estimate(h2); got != 1 {
+		t.Errorf("a.Estimate(h2)=%d, want 1", got)
+	}
+
+	if err := a.Merge(New(64)); err != ErrMismatch {
+		t.Errorf("a.Merge(wider)=%v, want %v", err, ErrMismatch)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	cm := New(32)
+	hash := uint64(0x0ddc0ffeebadf00d)
+	cm.Add(hash)
+	cm.Add(hash)
+	cm.Add(hash)
+
+	b, err := cm.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Sketch
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary()=%v", err)
+	}
+	if e := got.Estimate(hash); e != 3 {
+		t.Errorf("got.Estimate(%x)=%d, want 3", hash, e)
+	}
+
+	if err := got.UnmarshalBinary(b[:len(b)-1]); err != ErrCorrupt {
+		t.Errorf("UnmarshalBinary(truncated)=%v, want %v", err, ErrCorrupt)
+	}
+}
+
+func BenchmarkCMAddSaturated(b *testing.B) {
+	cm := New(32)
+	hash := uint64(0x0ddc0ffeebadf00d)
+	for i := 0; i < b.N; i++ {
+		cm.Add(hash)
+	}
+}
+
+var SinkByte byte
+
+func BenchmarkCMEstimate(b *testing.B) {
+	cm := New(32)
+	hash := uint64(0x0ddc0ffeebadf00d)
+	cm.Add(hash)
+	for i := 0; i < b.N; i++ {
+		SinkByte = cm.Estimate(hash)
+	}
+}
+
+func BenchmarkCMReset(b *testing.B) {
+	cm := New(3200)
+	for i := 0; i < b.N; i++ {
+		cm.Reset()
+	}
+}
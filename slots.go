@@ -0,0 +1,88 @@
+package tinylfu
+
+import "github.com/dgryski/go-tinylfu/internal/ilist"
+
+// list ids for the item storage.  The main cache's lists start at listOne;
+// policies needing more than two lists use listOne+1, listOne+2, ...
+const (
+	listWindow = iota
+	listFree
+	listPinned
+	listNegative
+	listOne
+	listTwo
+	nlists
+)
+
+// slots is the preallocated item storage shared by the window and main
+// caches.  Items are linked into their lists by index, so adding an item
+// never allocates and the lists contain no pointers for the GC to scan.
+type slots[K comparable, V any] struct {
+	data  map[K]int32
+	items []slruItem[K, V]
+	ll    *ilist.Lists
+}
+
+func newSlots[K comparable, V any](n int, lists int) *slots[K, V] {
+	s := &slots[K, V]{
+		data:  make(map[K]int32, n),
+		items: make([]slruItem[K, V], n),
+		ll:    ilist.New(n, lists),
+	}
+
+	for i := 0; i < n; i++ {
+		s.items[i].listid = listFree
+		s.ll.PushBack(listFree, int32(i))
+	}
+
+	return s
+}
+
+// alloc stores newitem in a free slot at the front of list l, replacing any
+// item already stored for its key
+func (s *slots[K, V]) alloc(l int, newitem slruItem[K, V]) int32 {
+	if old, ok := s.data[newitem.key]; ok {
+		s.free(old)
+	}
+
+	i := s.ll.Front(listFree)
+	s.ll.Remove(listFree, i)
+
+	newitem.listid = l
+	s.items[i] = newitem
+	s.data[newitem.key] = i
+	s.ll.PushFront(l, i)
+	return i
+}
+
+// replace stores newitem in place of the item at the back of list l,
+// returning the old item.  Any item already stored for newitem's key is
+// removed.
+func (s *slots[K, V]) replace(l int, newitem slruItem[K, V]) slruItem[K, V] {
+	if old, ok := s.data[newitem.key]; ok {
+		s.free(old)
+	}
+
+	i := s.ll.Back(l)
+	item := &s.items[i]
+
+	delete(s.data, item.key) // delete old key
+
+	oitem := *item
+	newitem.listid = l
+	*item = newitem
+
+	s.data[item.key] = i // insert new key
+	s.ll.MoveToFront(l, i)
+	return oitem
+}
+
+// free removes the item in slot i from its list and the map
+func (s *slots[K, V]) free(i int32) {
+	item := &s.items[i]
+	s.ll.Remove(item.listid, i)
+	delete(s.data, item.key)
+
+	*item = slruItem[K, V]{listid: listFree}
+	s.ll.PushFront(listFree, i)
+}
@@ -0,0 +1,31 @@
+package tinylfu
+
+// Stats holds counters describing the activity of a cache.
+type Stats struct {
+	Hits       uint64 // lookups which found their key
+	Misses     uint64 // lookups which didn't find their key
+	Negatives  uint64 // lookups which found a negative entry for their key
+	L2Hits     uint64 // hits served by the second-level store, also counted in Hits
+	Evictions  uint64 // items removed to make room for others, including rejected candidates
+	Rejections uint64 // window items refused admission to the main cache, and refused negative entries
+	Size       int    // items currently in the cache
+}
+
+// Len returns the number of items in the cache, not counting negative entries.
+func (t *T[K, V]) Len() int {
+	return len(t.data) - t.neg.Len()
+}
+
+// Stats returns the cache's counters.
+func (t *T[K, V]) Stats() Stats {
+	s := t.stats
+	s.Size = t.Len()
+	return s
+}
+
+// Stats returns the cache's counters.
+func (c *BytesCache) Stats() Stats {
+	s := c.stats
+	s.Size = c.Len()
+	return s
+}
@@ -0,0 +1,52 @@
+package tinylfu
+
+// tagRef records the generation of a tag when an item was added with it
+type tagRef struct {
+	tag string
+	gen uint64
+}
+
+// AddWithTags is like Add, but also associates key with tags so that it can
+// be removed with InvalidateTag.  Any tags key already had are replaced.
+// Adding a key with Add leaves its tags unchanged.
+func (t *T[K, V]) AddWithTags(key K, val V, tags ...string) {
+	refs := make([]tagRef, len(tags))
+	for j, tag := range tags {
+		refs[j] = tagRef{tag, t.tags[tag]}
+	}
+
+	if i, ok := t.lookup(key); ok {
+		t.items[i].tags = refs
+		t.update(i, val)
+		return
+	}
+
+	// new items always enter the window
+	t.add(key, t.hash(key), val)
+	t.items[t.data[key]].tags = refs
+}
+
+// InvalidateTag removes every item added with tag.  It takes constant time:
+// tagged items are discarded lazily, when they are next looked up or reach
+// the end of the window or the main cache, and until then still count
+// towards Len.  Invalidated items are never passed to OnEvict.
+//
+// The cache remembers the generation of every tag ever invalidated, so each
+// distinct tag costs a map entry for the life of the cache.  Tags should come
+// from a bounded set, such as tenant IDs, rather than being unique per item.
+func (t *T[K, V]) InvalidateTag(tag string) {
+	if t.tags == nil {
+		t.tags = make(map[string]uint64)
+	}
+	t.tags[tag]++
+}
+
+// stale reports whether one of the item's tags has been invalidated
+func (t *T[K, V]) stale(item *slruItem[K, V]) bool {
+	for _, r := range item.tags {
+		if t.tags[r.tag] != r.gen {
+			return true
+		}
+	}
+	return false
+}
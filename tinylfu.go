@@ -4,19 +4,33 @@
 */
 package tinylfu
 
-import "github.com/dgryski/go-tinylfu/internal/list"
-
 type T[K comparable, V any] struct {
-	c       *cm4
-	bouncer *doorkeeper
-	w       int
-	samples int
+	admission
 	lru     *lruCache[K, V]
-	slru    *slruCache[K, V]
-	data    map[K]*list.Element[*slruItem[K, V]]
+	main    policy[K, V]
+	slots   *slots[K, V]
+	data    map[K]int32
+	items   []slruItem[K, V]
 	hash    func(K) uint64
 	evict   func(K, V)
 	replace func(K, V)
+	remove  func(K, V)
+	record  RecordPolicy[K]
+	stats   Stats
+
+	observer Observer[K]
+	topk     *topK[K]
+
+	pincap     int
+	mainPolicy Policy
+	segments   []int
+	tags       map[string]uint64 // current generation of each tag
+
+	neg    *lruCache[K, V] // negative entries
+	negcap int
+	now    func() int64
+
+	l2 L2[K, V]
 }
 
 type Option[K comparable, V any] func(*T[K, V])
@@ -29,7 +43,83 @@ func OnReplace[K comparable, V any](f func(key K, old V)) Option[K, V] {
 	return func(t *T[K, V]) { t.replace = f }
 }
 
+// Access identifies the cache operation being considered by a RecordPolicy.
+type Access int
+
+const (
+	// AccessGet is a lookup with Get.
+	AccessGet Access = iota
+	// AccessAdd is an insertion or update with Add.
+	AccessAdd
+)
+
+// RecordPolicy reports whether an access to key should be recorded in the
+// frequency sketch.  Recorded accesses also advance the sample window, so the
+// policy determines which operations age the sketch.  Updating a key that is
+// already cached always bumps its frequency; the policy only decides whether
+// the update also counts towards the sample window.
+type RecordPolicy[K comparable] func(key K, access Access) bool
+
+// RecordOnGet records only lookups.  Adding a new key is not counted, although
+// updating an existing key still bumps its frequency.  This is the default.
+func RecordOnGet[K comparable](key K, access Access) bool {
+	return access == AccessGet
+}
+
+// RecordOnGetAndAdd records both lookups and insertions, so that write-heavy
+// workloads age the sketch as well.
+func RecordOnGetAndAdd[K comparable](key K, access Access) bool {
+	return true
+}
+
+// Record sets the policy deciding which accesses are counted in the frequency sketch.
+func Record[K comparable, V any](p RecordPolicy[K]) Option[K, V] {
+	return func(t *T[K, V]) { t.record = p }
+}
+
 func New[K comparable, V any](size int, samples int, hash func(K) uint64, options ...Option[K, V]) *T[K, V] {
+	window, probation, protected := segmentSizes(size)
+
+	t := &T[K, V]{
+		admission: newAdmission(size, samples),
+
+		hash:    hash,
+		evict:   ignore[K, V],
+		replace: ignore[K, V],
+		remove:  ignore[K, V],
+		record:  RecordOnGet[K],
+		now:     nanotime,
+	}
+
+	for _, option := range options {
+		option(t)
+	}
+
+	caps := []int{probation, protected}
+	if t.segments != nil {
+		caps = splitSegments(probation+protected, t.segments)
+	}
+
+	lists := listOne + len(caps)
+	if lists < nlists {
+		lists = nlists
+	}
+
+	s := newSlots[K, V](window+probation+protected+t.pincap+t.negcap, lists)
+
+	t.slots = s
+	t.data = s.data
+	t.items = s.items
+	t.lru = newLRU(listWindow, window, s)
+	t.main = newPolicy(t.mainPolicy, caps, s)
+	t.neg = newLRU(listNegative, t.negcap, s)
+
+	return t
+}
+
+// segmentSizes splits a cache of size items into the capacities of the window
+// LRU and the probation and protected segments of the main SLRU.
+func segmentSizes(size int) (window, probation, protected int) {
 	const lruPct = 1
 
 	lruSize := (lruPct * size) / 100
@@ -45,113 +135,327 @@ func New[K comparable, V any](size int, samples int, hash func(K) uint64, option
 		slru20 = 1
 	}
 
-	data := make(map[K]*list.Element[*slruItem[K, V]], size)
-
-	t := &T[K, V]{
-		c:       newCM4(size),
-		w:       0,
-		samples: samples,
-		bouncer: newDoorkeeper(samples, 0.01),
+	return lruSize, slru20, slruSize - slru20
+}
 
-		data: data,
+// sample records an access to keyh in the frequency sketch, aging the sketch
+// once the sample window is full.
+func (t *T[K, V]) sample(keyh uint64) {
+	if !t.count(keyh) {
+		return
+	}
 
-		lru:  newLRU(lruSize, data),
-		slru: newSLRU(slru20, slruSize-slru20, data),
+	if t.observer != nil {
+		t.observer.OnReset()
+	}
+	if t.topk != nil {
+		t.topk.reset()
+	}
+}
 
-		hash:    hash,
-		evict:   ignore[K, V],
-		replace: ignore[K, V],
+// lookup returns the slot holding the value for key.  Invalidated and
+// expired items are discarded, and negative entries are ignored.
+func (t *T[K, V]) lookup(key K) (int32, bool) {
+	i, ok := t.data[key]
+	if !ok {
+		return 0, false
 	}
 
-	for _, option := range options {
-		option(t)
+	item := &t.items[i]
+	if t.stale(item) || t.expired(item) {
+		t.slots.free(i)
+		return 0, false
 	}
 
-	return t
+	return i, item.listid != listNegative
 }
 
 func (t *T[K, V]) Get(key K) (V, bool) {
+	record := t.record(key, AccessGet)
 
-	t.w++
-	if t.w == t.samples {
-		t.c.reset()
-		t.bouncer.reset()
-		t.w = 0
+	i, ok := t.lookup(key)
+	if !ok {
+		var keyh uint64
+		if record || t.l2 != nil {
+			keyh = t.hash(key)
+		}
+		if v, ok := t.fetch(key, keyh, record); ok {
+			return v, true
+		}
+		t.miss(key, keyh, record)
+		return *new(V), false
 	}
 
-	val, ok := t.data[key]
+	return t.hit(i, record), true
+}
+
+// GetHashed is like Get, but uses keyh as the hash of key instead of calling
+// the cache's hash function.  keyh must be the value the hash function would
+// return for key.
+func (t *T[K, V]) GetHashed(key K, keyh uint64) (V, bool) {
+	record := t.record(key, AccessGet)
+
+	i, ok := t.lookup(key)
 	if !ok {
-		keyh := t.hash(key)
-		t.c.add(keyh)
+		if v, ok := t.fetch(key, keyh, record); ok {
+			return v, true
+		}
+		t.miss(key, keyh, record)
 		return *new(V), false
 	}
 
-	item := val.Value
+	return t.hit(i, record), true
+}
 
-	t.c.add(item.keyh)
+// Frequency returns the estimated number of recent accesses to key: the
+// frequency sketch's estimate, plus one if the doorkeeper has seen the key.
+// It doesn't modify the cache.
+func (t *T[K, V]) Frequency(key K) int {
+	keyh := t.keyHash(key)
 
-	v := item.value
-	if item.listid == 0 {
-		t.lru.get(val)
-	} else {
-		t.slru.get(val)
+	f := int(t.c.Estimate(keyh))
+	if t.bouncer.Test(keyh) {
+		f++
 	}
+	return f
+}
 
-	return v, true
+// keyHash returns the hash of key, avoiding calling the hash function if key is cached
+func (t *T[K, V]) keyHash(key K) uint64 {
+	if i, ok := t.data[key]; ok {
+		return t.items[i].keyh
+	}
+	return t.hash(key)
 }
 
-func (t *T[K, V]) Add(key K, val V) {
+// miss updates the sketch state for a lookup of a key not in the cache
+func (t *T[K, V]) miss(key K, keyh uint64, record bool) {
+	if record {
+		t.sample(keyh)
+		if t.topk != nil {
+			t.topk.offer(key, t.c.Estimate(keyh))
+		}
+	}
+
+	t.stats.Misses++
+	if t.observer != nil {
+		t.observer.OnGet(key, false)
+	}
+}
+
+// hit updates the list and sketch state for a lookup of a cached item
+func (t *T[K, V]) hit(i int32, record bool) V {
+	item := &t.items[i]
 
-	if e, ok := t.data[key]; ok {
-		// Key is already in our cache.
-		// `Add` will act as a `Get` for list movements
-		item := e.Value
-		oval := item.value
-		item.value = val
-		t.c.add(item.keyh)
-
-		if item.listid == 0 {
-			t.lru.get(e)
-		} else {
-			t.slru.get(e)
+	if record {
+		t.sample(item.keyh)
+		if t.topk != nil {
+			t.topk.offer(item.key, t.c.Estimate(item.keyh))
 		}
+	}
+
+	t.stats.Hits++
+	if t.observer != nil {
+		t.observer.OnGet(item.key, true)
+	}
 
-		t.replace(key, oval)
+	v := item.value
+	t.touch(i)
+	return v
+}
+
+// touch updates the lists for an access to the item in slot i
+func (t *T[K, V]) touch(i int32) {
+	switch t.items[i].listid {
+	case listWindow:
+		t.lru.get(i)
+	case listPinned:
+		// pinned items aren't on an eviction list
+	default:
+		t.main.get(i)
+	}
+}
+
+func (t *T[K, V]) Add(key K, val V) {
+	if i, ok := t.lookup(key); ok {
+		t.update(i, val)
 		return
 	}
 
-	newitem := slruItem[K, V]{0, key, val, t.hash(key)}
+	t.add(key, t.hash(key), val)
+}
+
+// AddHashed is like Add, but uses keyh as the hash of key instead of calling
+// the cache's hash function.  keyh must be the value the hash function would
+// return for key.
+func (t *T[K, V]) AddHashed(key K, keyh uint64, val V) {
+	if i, ok := t.lookup(key); ok {
+		t.update(i, val)
+		return
+	}
 
+	t.add(key, keyh, val)
+}
+
+// update replaces the value of an item already in the cache
+func (t *T[K, V]) update(i int32, val V) {
+	// `Add` will act as a `Get` for list movements
+	item := &t.items[i]
+	oval := item.value
+	item.value = val
+	if t.record(item.key, AccessAdd) {
+		t.sample(item.keyh)
+	} else {
+		t.c.Add(item.keyh)
+	}
+
+	t.touch(i)
+
+	t.replace(item.key, oval)
+}
+
+// add inserts a key not currently in the cache
+func (t *T[K, V]) add(key K, keyh uint64, val V) {
+	newitem := slruItem[K, V]{key: key, value: val, keyh: keyh}
+
+	if t.record(key, AccessAdd) {
+		t.sample(newitem.keyh)
+	}
+
+	if t.l2 != nil {
+		t.l2.Remove(key)
+	}
+
+	t.insert(newitem)
+}
+
+// insert adds newitem to the window, admitting the item it displaces into
+// the main cache if it is popular enough
+func (t *T[K, V]) insert(newitem slruItem[K, V]) {
 	oitem, evicted := t.lru.add(newitem)
-	if !evicted {
+	if !evicted || t.stale(&oitem) {
+		// invalidated items are dropped without competing for admission
 		return
 	}
 
-	// estimate count of what will be evicted from slru
-	victim := t.slru.victim()
+	// estimate count of what will be evicted from the main cache
+	victim := t.main.victim()
+
+	// invalidated items make room without competing for it
+	for victim != nil && t.stale(victim) {
+		t.slots.free(t.data[victim.key])
+		victim = t.main.victim()
+	}
+
 	if victim == nil {
-		if oitem, evicted := t.slru.add(oitem); evicted {
-			t.evict(oitem.key, oitem.value)
+		if oitem, evicted := t.main.add(oitem); evicted {
+			t.drop(oitem)
 		}
 		return
 	}
 
-	if !t.bouncer.allow(oitem.keyh) {
-		t.evict(oitem.key, oitem.value)
-		return
-	}
+	admitted := t.bouncer.Insert(oitem.keyh)
+
+	// the estimates are only needed for rejected items if someone's watching
+	if admitted || t.observer != nil {
+		vcount := t.c.Estimate(victim.keyh)
+		ocount := t.c.Estimate(oitem.keyh)
+		admitted = admitted && ocount >= vcount
 
-	vcount := t.c.estimate(victim.keyh)
-	ocount := t.c.estimate(oitem.keyh)
+		if t.observer != nil {
+			t.observer.OnAdmit(oitem.key, victim.key, int(ocount), int(vcount), admitted)
+		}
+	}
 
-	if ocount < vcount {
-		t.evict(oitem.key, oitem.value)
+	if !admitted {
+		t.stats.Rejections++
+		t.drop(oitem)
 		return
 	}
 
-	if oitem, evicted := t.slru.add(oitem); evicted {
-		t.evict(oitem.key, oitem.value)
+	if oitem, evicted := t.main.add(oitem); evicted {
+		t.drop(oitem)
+	}
+}
+
+// drop evicts item from the cache
+func (t *T[K, V]) drop(item slruItem[K, V]) {
+	t.stats.Evictions++
+	if !t.stale(&item) {
+		t.spill(&item)
+		t.evict(item.key, item.value)
 	}
 }
 
 func ignore[K, V any](K, V) {}
+
+// GetMany looks up each of keys, returning a map of the keys that were found.
+// It is a convenience wrapper calling Get for each key, and allocates the
+// returned map on every call; GetSlice doesn't allocate, and GetSliceHashed
+// also avoids hashing.
+func (t *T[K, V]) GetMany(keys []K) map[K]V {
+	m := make(map[K]V, len(keys))
+	for _, k := range keys {
+		if v, ok := t.Get(k); ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// GetSlice looks up each of keys, storing the results in the corresponding
+// elements of vals and found.  It panics if vals or found are shorter than
+// keys.  Like GetMany, it calls Get for each key.
+func (t *T[K, V]) GetSlice(keys []K, vals []V, found []bool) {
+	if len(vals) < len(keys) || len(found) < len(keys) {
+		panic("tinylfu: GetSlice: vals or found shorter than keys")
+	}
+	for i, k := range keys {
+		vals[i], found[i] = t.Get(k)
+	}
+}
+
+// GetSliceHashed is like GetSlice, but uses hashes[i] as the hash of keys[i],
+// so the batch never calls the cache's hash function.  Each hash must be the
+// value the hash function would return for its key.  It panics if hashes,
+// vals or found are shorter than keys.
+func (t *T[K, V]) GetSliceHashed(keys []K, hashes []uint64, vals []V, found []bool) {
+	if len(hashes) < len(keys) || len(vals) < len(keys) || len(found) < len(keys) {
+		panic("tinylfu: GetSliceHashed: hashes, vals or found shorter than keys")
+	}
+	for i, k := range keys {
+		vals[i], found[i] = t.GetHashed(k, hashes[i])
+	}
+}
+
+// AddMany adds every key/value pair in items to the cache.  It is a
+// convenience wrapper calling Add for each pair.
+func (t *T[K, V]) AddMany(items map[K]V) {
+	for k, v := range items {
+		t.Add(k, v)
+	}
+}
+
+// AddSlice adds keys[i], vals[i] to the cache for each i.  It panics if vals
+// is shorter than keys.  Like AddMany, it calls Add for each pair.
+func (t *T[K, V]) AddSlice(keys []K, vals []V) {
+	if len(vals) < len(keys) {
+		panic("tinylfu: AddSlice: vals shorter than keys")
+	}
+	for i, k := range keys {
+		t.Add(k, vals[i])
+	}
+}
+
+// AddSliceHashed is like AddSlice, but uses hashes[i] as the hash of keys[i],
+// so the batch never calls the cache's hash function.  Each hash must be the
+// value the hash function would return for its key.  It panics if hashes or
+// vals are shorter than keys.
+func (t *T[K, V]) AddSliceHashed(keys []K, hashes []uint64, vals []V) {
+	if len(hashes) < len(keys) || len(vals) < len(keys) {
+		panic("tinylfu: AddSliceHashed: hashes or vals shorter than keys")
+	}
+	for i, k := range keys {
+		t.AddHashed(k, hashes[i], vals[i])
+	}
+}
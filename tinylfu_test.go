@@ -1,9 +1,14 @@
 package tinylfu
 
 import (
+	"fmt"
 	"hash/maphash"
+	"math/rand"
 	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAddAlreadyInCache(t *testing.T) {
@@ -103,6 +108,954 @@ func TestNoCachePollution(t *testing.T) {
 	}
 }
 
+func TestTiny(t *testing.T) {
+	// a cache this small has no room for a protected segment
+	c := New[int, int](2, 100, func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 })
+
+	for i := 0; i < 10; i++ {
+		k := i % 3
+		c.Add(k, k)
+		for j := 0; j < 3; j++ {
+			if v, ok := c.Get(k); !ok || v != k {
+				t.Fatalf("c.Get(%d)=%d,%v, want %d,true", k, v, ok, k)
+			}
+		}
+	}
+	if n := len(c.data); n != 2 {
+		t.Errorf("%d items cached, want 2", n)
+	}
+}
+
+func TestRecordPolicy(t *testing.T) {
+	const samples = 10
+
+	tests := []struct {
+		name   string
+		policy RecordPolicy[int]
+		// window position after samples-1 adds
+		wantW int
+		// estimate for key 0 after samples adds
+		wantEst byte
+	}{
+		// adds are never counted, so the sketch doesn't age
+		{"get", RecordOnGet[int], 0, 0},
+		// every add is counted, and the final add resets the sketch
+		{"get+add", RecordOnGetAndAdd[int], samples - 1, 0},
+		// only even keys are counted, so the window never fills
+		{"custom", func(k int, a Access) bool { return a == AccessGet || k%2 == 0 }, samples / 2, 1},
+	}
+
+	for _, tt := range tests {
+		c := New[int, int](100, samples, func(k int) uint64 { return uint64(k) }, Record[int, int](tt.policy))
+
+		for i := 0; i < samples-1; i++ {
+			c.Add(i, i)
+		}
+		if c.w != tt.wantW {
+			t.Errorf("%s: window=%d after %d adds, want %d", tt.name, c.w, samples-1, tt.wantW)
+		}
+
+		c.Add(samples-1, samples-1)
+		if got := c.c.Estimate(0); got != tt.wantEst {
+			t.Errorf("%s: estimate(0)=%d, want %d", tt.name, got, tt.wantEst)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	c := New[int, int](100, 1000, func(k int) uint64 { return uint64(k) })
+
+	c.AddMany(map[int]int{1: 10, 2: 20})
+	c.AddSlice([]int{3, 4}, []int{30, 40})
+
+	got := c.GetMany([]int{1, 2, 3, 4, 5})
+	want := map[int]int{1: 10, 2: 20, 3: 30, 4: 40}
+	if len(got) != len(want) {
+		t.Errorf("GetMany()=%v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("GetMany()[%d]=%d, want %d", k, got[k], v)
+		}
+	}
+
+	keys := []int{4, 5, 1}
+	vals := make([]int, len(keys))
+	found := make([]bool, len(keys))
+	c.GetSlice(keys, vals, found)
+	if !slices.Equal(vals, []int{40, 0, 10}) || !slices.Equal(found, []bool{true, false, true}) {
+		t.Errorf("GetSlice()=%v,%v, want %v,%v", vals, found, []int{40, 0, 10}, []bool{true, false, true})
+	}
+
+	// the hashed variants never call the hash function
+	var hashes int
+	h := New[int, int](100, 1000, func(k int) uint64 { hashes++; return uint64(k) },
+		Record[int, int](RecordOnGetAndAdd[int]),
+	)
+	hkeys := []int{1, 2, 3}
+	hh := []uint64{1, 2, 3}
+	h.AddSliceHashed(hkeys[:2], hh[:2], []int{10, 20})
+	h.GetSliceHashed(hkeys, hh, vals, found)
+	if !slices.Equal(vals, []int{10, 20, 0}) || !slices.Equal(found, []bool{true, true, false}) {
+		t.Errorf("GetSliceHashed()=%v,%v, want %v,%v", vals, found, []int{10, 20, 0}, []bool{true, true, false})
+	}
+	if hashes != 0 {
+		t.Errorf("hash function called %d times by the hashed batch functions", hashes)
+	}
+
+	// short slices panic even if they have the capacity for every key
+	short := make([]int, 1, len(keys))
+	for name, f := range map[string]func(){
+		"GetSlice": func() { c.GetSlice(keys, short, found) },
+		"AddSlice": func() { c.AddSlice(keys, short) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s with a short vals slice didn't panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
+
+func TestHashed(t *testing.T) {
+	var calls int
+	c := New[int, int](100, 1000, func(k int) uint64 { calls++; return uint64(k) })
+
+	c.AddHashed(1, 1, 10)
+	c.AddHashed(1, 1, 11)
+	if v, ok := c.GetHashed(1, 1); !ok || v != 11 {
+		t.Errorf("c.GetHashed(1)=%d,%v, want 11,true", v, ok)
+	}
+	if _, ok := c.GetHashed(2, 2); ok {
+		t.Errorf("c.GetHashed(2) found, want miss")
+	}
+	if got := c.c.Estimate(2); got != 1 {
+		t.Errorf("estimate(2)=%d, want 1", got)
+	}
+	if calls != 0 {
+		t.Errorf("hash called %d times, want 0", calls)
+	}
+}
+
+func TestAddNoAlloc(t *testing.T) {
+	c := New[int, int](1000, 10000, func(k int) uint64 { return uint64(k) })
+
+	var k int
+	for ; k < 2000; k++ {
+		c.Add(k, k)
+	}
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		c.Add(k, k)
+		k++
+	})
+	if allocs > 0.1 {
+		t.Errorf("Add allocated %v times per call, want 0", allocs)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := New[string, string](2, 20, func(k string) uint64 { return uint64(len(k)) })
+
+	c.Add("A", "1")
+	c.Add("BB", "2")
+	c.Add("CCC", "3")
+	c.Get("A")
+	c.Get("BB")
+
+	want := Stats{Hits: 1, Misses: 1, Evictions: 1, Rejections: 1, Size: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("c.Stats()=%+v, want %+v", got, want)
+	}
+}
+
+type admitEvent struct {
+	candidate, victim int
+	cfreq, vfreq      int
+	admitted          bool
+}
+
+type testObserver struct {
+	gets   []bool
+	admits []admitEvent
+	resets int
+}
+
+func (o *testObserver) OnGet(key int, hit bool) { o.gets = append(o.gets, hit) }
+func (o *testObserver) OnAdmit(candidate, victim int, cfreq, vfreq int, admitted bool) {
+	o.admits = append(o.admits, admitEvent{candidate, victim, cfreq, vfreq, admitted})
+}
+func (o *testObserver) OnReset() { o.resets++ }
+
+func TestObserver(t *testing.T) {
+	var o testObserver
+	c := New[int, int](2, 3, func(k int) uint64 { return uint64(k) }, Observe[int, int](&o))
+
+	c.Add(1, 1)
+	c.Add(2, 2) // 1 moves to main without competing
+	c.Add(3, 3) // 2 is new to the doorkeeper
+	c.Add(2, 2) // as is 3
+	c.Get(4)
+	c.Get(4)
+	c.Add(4, 4) // 2 has been seen before, and is as popular as 1
+	c.Get(2)    // fills the sample window
+
+	wantAdmits := []admitEvent{
+		{2, 1, 0, 0, false},
+		{3, 1, 0, 0, false},
+		{2, 1, 0, 0, true},
+	}
+	if !slices.Equal(o.admits, wantAdmits) {
+		t.Errorf("admits=%+v, want %+v", o.admits, wantAdmits)
+	}
+	if wantGets := []bool{false, false, true}; !slices.Equal(o.gets, wantGets) {
+		t.Errorf("gets=%v, want %v", o.gets, wantGets)
+	}
+	if o.resets != 1 {
+		t.Errorf("resets=%d, want 1", o.resets)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	c := New[int, int](2, 100, func(k int) uint64 { return uint64(k) })
+
+	c.Add(1, 1)
+	c.Add(2, 2)
+	c.Get(3)
+	c.Get(3)
+
+	tests := []struct {
+		key  int
+		want Explanation[int]
+	}{
+		{1, Explanation[int]{Segment: SegmentProbation, HasVictim: true, Victim: 1, Outcome: OutcomeCached}},
+		{2, Explanation[int]{Segment: SegmentWindow, HasVictim: true, Victim: 1, Outcome: OutcomeRejectedDoorkeeper}},
+		{3, Explanation[int]{Frequency: 2, HasVictim: true, Victim: 1, Outcome: OutcomeRejectedDoorkeeper}},
+	}
+
+	for _, tt := range tests {
+		if got := c.Explain(tt.key); got != tt.want {
+			t.Errorf("c.Explain(%d)=%+v, want %+v", tt.key, got, tt.want)
+		}
+	}
+
+	// each rejection marks the candidate as seen by the doorkeeper
+	c.Add(3, 3)
+	c.Add(2, 2)
+	if got := c.Explain(3); got.Outcome != OutcomeAdmittedOverVictim || !got.Seen {
+		t.Errorf("c.Explain(3)=%+v, want seen and admitted over victim", got)
+	}
+
+	for i := 0; i < 3; i++ {
+		c.Get(1)
+	}
+	if got := c.Explain(3); got.Outcome != OutcomeRejectedFrequency || got.VictimFrequency != 3 {
+		t.Errorf("c.Explain(3)=%+v, want rejected by frequency against victim frequency 3", got)
+	}
+}
+
+func TestFrequency(t *testing.T) {
+	c := New[int, int](2, 100, func(k int) uint64 { return uint64(k) })
+
+	c.Get(1)
+	c.Get(1)
+	if got := c.Frequency(1); got != 2 {
+		t.Errorf("c.Frequency(1)=%d, want 2", got)
+	}
+
+	// 2 is rejected from the main cache, which marks it in the doorkeeper
+	c.Add(3, 3)
+	c.Add(2, 2)
+	c.Add(4, 4)
+	c.Add(5, 5)
+	if got := c.Frequency(2); got != 1 {
+		t.Errorf("c.Frequency(2)=%d, want 1", got)
+	}
+
+	if got := c.Frequency(1); got != 2 {
+		t.Errorf("c.Frequency(1)=%d after Frequency, want 2", got)
+	}
+}
+
+func TestMergePopularity(t *testing.T) {
+	hash := func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 }
+	peer := New[int, int](100, 1000, hash)
+	c := New[int, int](100, 1000, hash)
+
+	for i := 0; i < 5; i++ {
+		peer.Get(1)
+	}
+	c.Get(1)
+
+	b, err := peer.Popularity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MergePopularity(b); err != nil {
+		t.Fatalf("c.MergePopularity()=%v", err)
+	}
+	if got := c.Frequency(1); got != 6 {
+		t.Errorf("c.Frequency(1)=%d, want 6", got)
+	}
+
+	other := New[int, int](1000, 1000, hash)
+	if err := other.MergePopularity(b); err != ErrPopularity {
+		t.Errorf("other.MergePopularity()=%v, want %v", err, ErrPopularity)
+	}
+	for _, bad := range [][]byte{b[:len(b)/2], {3, 1, 2, 3}, nil} {
+		if err := c.MergePopularity(bad); err != ErrPopularity {
+			t.Errorf("c.MergePopularity(% x)=%v, want %v", bad, err, ErrPopularity)
+		}
+	}
+}
+
+func TestTopK(t *testing.T) {
+	hash := func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 }
+	c := New[int, int](100, 1000, hash, TrackTopK[int, int](3))
+
+	c.Add(1, 1)
+	for k := 1; k <= 10; k++ {
+		for i := 0; i < k; i++ {
+			c.Get(k)
+		}
+	}
+
+	want := []HotKey[int]{{10, 10}, {9, 9}}
+	if got := c.TopK(2); !slices.Equal(got, want) {
+		t.Errorf("c.TopK(2)=%v, want %v", got, want)
+	}
+	if got := c.TopK(5); len(got) != 3 {
+		t.Errorf("len(c.TopK(5))=%d, want 3", len(got))
+	}
+	if got := c.TopK(-1); len(got) != 0 {
+		t.Errorf("c.TopK(-1)=%v, want none", got)
+	}
+
+	if got := New[int, int](100, 1000, hash).TopK(2); got != nil {
+		t.Errorf("TopK()=%v without tracking, want nil", got)
+	}
+}
+
+func TestPinned(t *testing.T) {
+	var evicted []int
+	c := New[int, int](10, 1000, func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 },
+		PinnedCapacity[int, int](2),
+		OnEvict(func(k, v int) { evicted = append(evicted, k) }),
+	)
+
+	if !c.AddPinned(1, 1) {
+		t.Fatalf("c.AddPinned(1) failed")
+	}
+	c.Add(2, 2)
+	if !c.Pin(2) {
+		t.Fatalf("c.Pin(2) failed")
+	}
+	if c.AddPinned(3, 3) || c.Pin(4) {
+		t.Errorf("pinned beyond capacity")
+	}
+
+	for i := 100; i < 200; i++ {
+		c.Add(i, i)
+	}
+
+	for _, k := range []int{1, 2} {
+		if v, ok := c.Get(k); !ok || v != k {
+			t.Errorf("c.Get(%d)=%d,%v, want pinned value", k, v, ok)
+		}
+		if slices.Contains(evicted, k) {
+			t.Errorf("pinned key %d evicted", k)
+		}
+	}
+	if got := c.Explain(1).Segment; got != SegmentPinned {
+		t.Errorf("c.Explain(1).Segment=%v, want %v", got, SegmentPinned)
+	}
+	if c.Len() != 12 || c.Pinned() != 2 {
+		t.Errorf("c.Len()=%d c.Pinned()=%d, want 12, 2", c.Len(), c.Pinned())
+	}
+
+	if !c.Unpin(1) || c.Unpin(1) {
+		t.Errorf("c.Unpin(1) didn't succeed exactly once")
+	}
+	if got := c.Explain(1).Segment; got != SegmentWindow {
+		t.Errorf("c.Explain(1).Segment=%v after Unpin, want %v", got, SegmentWindow)
+	}
+	if !c.AddPinned(3, 3) {
+		t.Errorf("c.AddPinned(3) failed after Unpin")
+	}
+}
+
+func TestConditional(t *testing.T) {
+	var replaced []int
+	c := New[string, int](100, 1000, func(k string) uint64 { return uint64(len(k)) },
+		OnReplace(func(k string, v int) { replaced = append(replaced, v) }),
+	)
+
+	if v, loaded := c.AddIfAbsent("a", 1); loaded || v != 1 {
+		t.Errorf("c.AddIfAbsent(a, 1)=%d,%v, want 1,false", v, loaded)
+	}
+	if v, loaded := c.AddIfAbsent("a", 2); !loaded || v != 1 {
+		t.Errorf("c.AddIfAbsent(a, 2)=%d,%v, want 1,true", v, loaded)
+	}
+
+	if CompareAndSwap(c, "a", 5, 6) {
+		t.Errorf("CompareAndSwap(a, 5, 6) succeeded with a=1")
+	}
+	if !CompareAndSwap(c, "a", 1, 3) {
+		t.Errorf("CompareAndSwap(a, 1, 3) failed with a=1")
+	}
+	if CompareAndSwap(c, "b", 0, 1) {
+		t.Errorf("CompareAndSwap(b, 0, 1) succeeded with b absent")
+	}
+
+	incr := func(old int, ok bool) (int, ComputeOp) { return old + 1, ComputeSet }
+	c.Compute("a", incr)
+	c.Compute("b", incr)
+	c.Compute("c", func(int, bool) (int, ComputeOp) { return 0, ComputeKeep })
+
+	if v, _ := c.Get("a"); v != 4 {
+		t.Errorf("c.Get(a)=%d, want 4", v)
+	}
+	if v, _ := c.Get("b"); v != 1 {
+		t.Errorf("c.Get(b)=%d, want 1", v)
+	}
+	if _, ok := c.Get("c"); ok {
+		t.Errorf("c.Get(c) found after ComputeKeep")
+	}
+
+	c.Compute("a", func(int, bool) (int, ComputeOp) { return 0, ComputeDelete })
+	if _, ok := c.Get("a"); ok || c.Len() != 1 {
+		t.Errorf("a still cached after ComputeDelete")
+	}
+
+	if want := []int{1, 3}; !slices.Equal(replaced, want) {
+		t.Errorf("replaced=%v, want %v", replaced, want)
+	}
+}
+
+func TestComputeReentrant(t *testing.T) {
+	c := New[int, int](10, 1000, func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 })
+	for k := 0; k < 10; k++ {
+		c.Add(k, k)
+	}
+
+	// f moves key 9 out of the window, whose slot is reused for other keys
+	c.Compute(9, func(old int, ok bool) (int, ComputeOp) {
+		for k := 10; k < 20; k++ {
+			c.Add(k, k)
+		}
+		return 100, ComputeSet
+	})
+
+	if v, ok := c.Get(9); !ok || v != 100 {
+		t.Errorf("c.Get(9)=%d,%v, want 100,true", v, ok)
+	}
+	for k := 0; k < 20; k++ {
+		if v, ok := c.Get(k); ok && k != 9 && v != k {
+			t.Errorf("c.Get(%d)=%d, want %d", k, v, k)
+		}
+	}
+}
+
+func TestAdmitter(t *testing.T) {
+	a := NewAdmitter(100, 1000)
+
+	const hot, cold = 0x0ddc0ffeebadf00d, 0x1234567887654321
+
+	for i := 0; i < 5; i++ {
+		a.Record(hot)
+	}
+	a.Record(cold)
+
+	if a.Admit(hot, cold) {
+		t.Errorf("a.Admit(hot, cold)=true for unseen candidate")
+	}
+	if !a.Admit(hot, cold) {
+		t.Errorf("a.Admit(hot, cold)=false, want true")
+	}
+	a.Admit(cold, hot)
+	if a.Admit(cold, hot) {
+		t.Errorf("a.Admit(cold, hot)=true, want false")
+	}
+	if got := a.Estimate(hot); got != 5 {
+		t.Errorf("a.Estimate(hot)=%d, want 5", got)
+	}
+}
+
+func TestMainPolicies(t *testing.T) {
+	const size = 100
+
+	for _, p := range []Policy{PolicySLRU, PolicyLRU, PolicyClock, PolicyS3FIFO} {
+		var evictions int
+		c := New[uint64, uint64](size, 10*size, func(k uint64) uint64 { return k * 0x9e3779b97f4a7c15 },
+			MainPolicy[uint64, uint64](p),
+			OnEvict(func(k, v uint64) { evictions++ }),
+		)
+
+		r := rand.New(rand.NewSource(1))
+		z := rand.NewZipf(r, 1.1, 1, 10*size)
+
+		var hits int
+		const n = 100000
+		for i := 0; i < n; i++ {
+			k := z.Uint64()
+			v, ok := c.Get(k)
+			if !ok {
+				c.Add(k, k)
+				continue
+			}
+			hits++
+			if v != k {
+				t.Fatalf("policy %d: c.Get(%d)=%d", p, k, v)
+			}
+		}
+
+		if c.Len() != size || c.main.Len() != size-1 {
+			t.Errorf("policy %d: c.Len()=%d main=%d, want %d, %d", p, c.Len(), c.main.Len(), size, size-1)
+		}
+		if evictions != n-hits-size {
+			t.Errorf("policy %d: evictions=%d, want %d", p, evictions, n-hits-size)
+		}
+		if ratio := float64(hits) / n; ratio < 0.5 {
+			t.Errorf("policy %d: hit ratio %.2f, want >= 0.5", p, ratio)
+		}
+	}
+}
+
+func TestPeek(t *testing.T) {
+	const size = 100
+
+	for _, p := range []Policy{PolicySLRU, PolicyLRU, PolicyClock, PolicyS3FIFO} {
+		c := New[uint64, uint64](size, 10*size, func(k uint64) uint64 { return k * 0x9e3779b97f4a7c15 },
+			MainPolicy[uint64, uint64](p),
+		)
+
+		// the order of every list, and each item's list and hit count
+		state := func() string {
+			var b strings.Builder
+			for l := 0; l < nlists; l++ {
+				for i := c.slots.ll.Front(l); i >= 0; i = c.slots.ll.Next(l, i) {
+					item := &c.items[i]
+					fmt.Fprintf(&b, "%d:%d:%d ", item.listid, item.key, item.freq)
+				}
+			}
+			return b.String()
+		}
+
+		r := rand.New(rand.NewSource(1))
+		z := rand.NewZipf(r, 1.1, 1, 10*size)
+
+		for i := 0; i < 3000; i++ {
+			k := z.Uint64()
+			if _, ok := c.Get(k); !ok {
+				c.Add(k, k)
+			}
+
+			before := state()
+			c.Explain(k)
+			peek := c.main.peek()
+			if after := state(); after != before {
+				t.Fatalf("policy %d: Explain changed the cache's state", p)
+			}
+
+			victim := c.main.victim()
+			if (peek == nil) != (victim == nil) || peek != nil && peek.key != victim.key {
+				t.Fatalf("policy %d: peek()=%v, victim()=%v", p, peek, victim)
+			}
+		}
+	}
+}
+
+func TestSLRUSegments(t *testing.T) {
+	s := newSlots[int, int](6, listOne+3)
+	slru := newSLRU([]int{2, 2, 2}, s)
+
+	for k := 0; k < 6; k++ {
+		slru.add(slruItem[int, int]{key: k, value: k})
+	}
+
+	level := func(k int) int { return s.items[s.data[k]].listid - listOne }
+	get := func(k int) { slru.get(s.data[k]) }
+
+	get(0)
+	get(0)
+	get(0)
+	if l := level(0); l != 2 {
+		t.Errorf("level(0)=%d after 3 hits, want 2", l)
+	}
+
+	get(1)
+	get(2)
+	get(3) // level 1 is full, so 1 is demoted
+	for k, want := range map[int]int{0: 2, 1: 0, 2: 1, 3: 1, 4: 0, 5: 0} {
+		if l := level(k); l != want {
+			t.Errorf("level(%d)=%d, want %d", k, l, want)
+		}
+	}
+
+	if got, want := splitSegments(40, []int{1, 1, 1, 1}), []int{10, 10, 10, 10}; !slices.Equal(got, want) {
+		t.Errorf("splitSegments(40, 1:1:1:1)=%v, want %v", got, want)
+	}
+	if got, want := splitSegments(2, []int{1, 4}), []int{1, 1}; !slices.Equal(got, want) {
+		t.Errorf("splitSegments(2, 1:4)=%v, want %v", got, want)
+	}
+
+	if got, want := splitSegments(3, []int{1, 1, 1, 1}), []int{1, 1, 1}; !slices.Equal(got, want) {
+		t.Errorf("splitSegments(3, 1:1:1:1)=%v, want %v", got, want)
+	}
+	if got, want := splitSegments(7, []int{1, 1, 4}), []int{1, 1, 5}; !slices.Equal(got, want) {
+		t.Errorf("splitSegments(7, 1:1:4)=%v, want %v", got, want)
+	}
+
+	c := New[int, int](100, 1000, func(k int) uint64 { return uint64(k) }, SLRUSegments[int, int](1, 1, 1, 1))
+	if got := len(c.main.(*slruCache[int, int]).caps); got != 4 {
+		t.Errorf("segments=%d, want 4", got)
+	}
+
+	// in a tiny cache, hits still promote items to the top segment
+	c = New[int, int](4, 1000, func(k int) uint64 { return uint64(k) }, SLRUSegments[int, int](1, 1, 1, 1))
+	for k := 0; k < 4; k++ {
+		c.Add(k, k)
+	}
+	for j := 0; j < 3; j++ {
+		c.Get(0)
+	}
+	top := len(c.main.(*slruCache[int, int]).caps) - 1
+	if l := c.items[c.data[0]].listid - listOne; l != top {
+		t.Errorf("level(0)=%d after 3 hits in a tiny cache, want %d", l, top)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("SLRUSegments(1, 0, 1) didn't panic")
+		}
+	}()
+	SLRUSegments[int, int](1, 0, 1)
+}
+
+func TestTags(t *testing.T) {
+	var evicted []string
+	s := maphash.MakeSeed()
+	c := New[string, int](10, 1000, func(k string) uint64 { return maphash.String(s, k) },
+		OnEvict(func(k string, v int) { evicted = append(evicted, k) }),
+	)
+
+	c.AddWithTags("a1", 1, "tenant-a")
+	c.AddWithTags("a2", 2, "tenant-a", "shared")
+	c.AddWithTags("b1", 3, "tenant-b")
+	c.Add("x", 4)
+
+	c.InvalidateTag("tenant-a")
+
+	for _, k := range []string{"a1", "a2"} {
+		if _, ok := c.Get(k); ok {
+			t.Errorf("c.Get(%s) found after invalidating its tag", k)
+		}
+	}
+	for _, k := range []string{"b1", "x"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("c.Get(%s) missing after invalidating another tag", k)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("c.Len()=%d, want 2", c.Len())
+	}
+
+	// re-adding after invalidation picks up the new generation
+	c.AddWithTags("a1", 5, "tenant-a")
+	if v, ok := c.Get("a1"); !ok || v != 5 {
+		t.Errorf("c.Get(a1)=%d,%v, want 5,true", v, ok)
+	}
+
+	// invalidated items reaching eviction aren't reported
+	c.InvalidateTag("tenant-b")
+	for i := 0; i < 100; i++ {
+		c.Add(strconv.Itoa(i), i)
+	}
+	if slices.Contains(evicted, "b1") {
+		t.Errorf("invalidated b1 passed to OnEvict")
+	}
+}
+
+func TestTagsStaleVictims(t *testing.T) {
+	s := maphash.MakeSeed()
+	c := New[string, int](100, 10000, func(k string) uint64 { return maphash.String(s, k) })
+
+	for i := 0; i < 100; i++ {
+		c.AddWithTags("hot"+strconv.Itoa(i), i, "hot")
+	}
+	for j := 0; j < 10; j++ {
+		for i := 0; i < 100; i++ {
+			c.Get("hot" + strconv.Itoa(i))
+		}
+	}
+	c.InvalidateTag("hot")
+
+	// invalidated items make way for new ones regardless of their frequency
+	rejections := c.Stats().Rejections
+	for i := 0; i < 20; i++ {
+		c.Add("new"+strconv.Itoa(i), i)
+	}
+	if r := c.Stats().Rejections - rejections; r != 0 {
+		t.Errorf("%d new items rejected in favour of invalidated ones", r)
+	}
+	for i := 0; i < 20; i++ {
+		if _, ok := c.Get("new" + strconv.Itoa(i)); !ok {
+			t.Errorf("c.Get(new%d) missing", i)
+		}
+	}
+}
+
+func TestTagsStaleCandidate(t *testing.T) {
+	var evicted []string
+	s := maphash.MakeSeed()
+	c := New[string, int](100, 10000, func(k string) uint64 { return maphash.String(s, k) },
+		OnEvict(func(k string, v int) { evicted = append(evicted, k) }),
+	)
+
+	for i := 0; i < 100; i++ {
+		c.Add(strconv.Itoa(i), i)
+	}
+
+	// a hot candidate the doorkeeper has seen would win admission
+	for j := 0; j < 10; j++ {
+		c.Get("cand")
+	}
+	c.bouncer.Insert(maphash.String(s, "cand"))
+	c.AddWithTags("cand", -1, "a")
+	c.InvalidateTag("a")
+
+	evicted = nil
+	st := c.Stats()
+	c.Add("new", -2)
+
+	if len(evicted) != 0 {
+		t.Errorf("invalidated candidate evicted %v", evicted)
+	}
+	if got := c.Stats(); got.Rejections != st.Rejections || got.Evictions != st.Evictions {
+		t.Errorf("c.Stats()=%+v, want the invalidated candidate dropped uncounted", got)
+	}
+	if _, ok := c.data["cand"]; ok {
+		t.Errorf("invalidated candidate still cached")
+	}
+}
+
+func TestRemoveIf(t *testing.T) {
+	var removed []string
+	s := maphash.MakeSeed()
+	c := New[string, int](100, 1000, func(k string) uint64 { return maphash.String(s, k) },
+		PinnedCapacity[string, int](1),
+		OnRemove(func(k string, v int) { removed = append(removed, k) }),
+	)
+
+	for i := 0; i < 10; i++ {
+		c.Add("user1/"+strconv.Itoa(i), i)
+		c.Add("user2/"+strconv.Itoa(i), i)
+	}
+	c.Pin("user1/3")
+
+	n := c.RemoveIf(func(k string, v int) bool { return strings.HasPrefix(k, "user1/") })
+	if n != 10 || len(removed) != 10 {
+		t.Errorf("c.RemoveIf()=%d with %d callbacks, want 10", n, len(removed))
+	}
+	if c.Len() != 10 || c.Pinned() != 0 {
+		t.Errorf("c.Len()=%d c.Pinned()=%d, want 10, 0", c.Len(), c.Pinned())
+	}
+
+	if v, ok := c.Remove("user2/5"); !ok || v != 5 {
+		t.Errorf("c.Remove(user2/5)=%d,%v, want 5,true", v, ok)
+	}
+	if _, ok := c.Remove("user2/5"); ok {
+		t.Errorf("c.Remove(user2/5) succeeded twice")
+	}
+	if _, ok := c.Get("user2/5"); ok {
+		t.Errorf("c.Get(user2/5) found after removal")
+	}
+}
+
+func TestNegative(t *testing.T) {
+	var now int64
+	s := maphash.MakeSeed()
+	c := New[string, int](100, 1000, func(k string) uint64 { return maphash.String(s, k) },
+		NegativeCapacity[string, int](2),
+	)
+	c.now = func() int64 { return now }
+
+	if !c.AddNegative("a", time.Second) {
+		t.Fatalf("c.AddNegative(a) failed")
+	}
+	if _, r := c.GetResult("a"); r != ResultNegative {
+		t.Errorf("c.GetResult(a)=%v, want negative", r)
+	}
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Errorf("negative entry visible to Get or Len")
+	}
+
+	c.Add("a", 1)
+	if v, r := c.GetResult("a"); r != ResultHit || v != 1 {
+		t.Errorf("c.GetResult(a)=%d,%v after Add, want 1,hit", v, r)
+	}
+
+	c.AddNegative("a", time.Second)
+	if _, r := c.GetResult("a"); r != ResultNegative {
+		t.Errorf("c.GetResult(a)=%v after AddNegative, want negative", r)
+	}
+
+	now += int64(2 * time.Second)
+	if e := c.Explain("a"); e.Segment != SegmentNone {
+		t.Errorf("c.Explain(a).Segment=%v after expiry, want none", e.Segment)
+	}
+	if _, r := c.GetResult("a"); r != ResultMiss {
+		t.Errorf("c.GetResult(a)=%v after expiry, want miss", r)
+	}
+
+	// popular negative entries are kept over new ones
+	c.AddNegative("b", time.Second)
+	c.AddNegative("c", time.Second)
+	for i := 0; i < 5; i++ {
+		c.GetResult("b")
+		c.GetResult("c")
+	}
+	if c.AddNegative("d", time.Second) {
+		t.Errorf("c.AddNegative(d) admitted over popular entries")
+	}
+	if _, r := c.GetResult("b"); r != ResultNegative {
+		t.Errorf("c.GetResult(b)=%v, want negative", r)
+	}
+
+	// but expired entries are always replaced
+	now += int64(2 * time.Second)
+	if !c.AddNegative("d", time.Second) {
+		t.Errorf("c.AddNegative(d) failed with expired entries")
+	}
+
+	if st := c.Stats(); st.Negatives != 13 || st.Hits != 1 {
+		t.Errorf("c.Stats()=%+v, want 13 negatives and 1 hit", st)
+	}
+
+	c = New[string, int](100, 1000, func(k string) uint64 { return maphash.String(s, k) })
+	if c.AddNegative("a", time.Second) {
+		t.Errorf("c.AddNegative(a) succeeded without negative capacity")
+	}
+}
+
+func TestSpillTo(t *testing.T) {
+	l := openTestLog(t)
+	c := New[int, int](100, 1000, func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 },
+		SpillTo[int, int](l),
+	)
+
+	for i := 0; i < 1000; i++ {
+		c.Add(i, i)
+	}
+	if c.Len()+l.Len() != 1000 {
+		t.Fatalf("c.Len()=%d l.Len()=%d, want 1000 in total", c.Len(), l.Len())
+	}
+
+	for i := 0; i < 1000; i++ {
+		if v, ok := c.Get(i); !ok || v != i {
+			t.Errorf("c.Get(%d)=%d,%v, want %d,true", i, v, ok, i)
+		}
+	}
+	if st := c.Stats(); st.L2Hits == 0 || st.Misses != 0 {
+		t.Errorf("c.Stats()=%+v, want L2 hits and no misses", st)
+	}
+
+	// a key promoted from L2 is back in the cache
+	k := 0
+	for ; k < 1000; k++ {
+		if _, ok := l.Get(k); ok {
+			break
+		}
+	}
+	c.Get(k)
+	if _, ok := l.Get(k); ok {
+		t.Errorf("key %d still in L2 after promotion", k)
+	}
+	if e := c.Explain(k); e.Segment != SegmentWindow {
+		t.Errorf("key %d in segment %v after promotion, want window", k, e.Segment)
+	}
+
+	c.Remove(k)
+	if _, ok := c.Get(k); ok {
+		t.Errorf("c.Get(%d) found after removal", k)
+	}
+
+	// the conditional operations see keys held in L2
+	spilled := func() int {
+		var k int
+		l.Range(func(key, val int) bool { k = key; return false })
+		return k
+	}
+	k = spilled()
+	if v, loaded := c.AddIfAbsent(k, -1); !loaded || v != k {
+		t.Errorf("c.AddIfAbsent(%d)=%d,%v for a spilled key, want %d,true", k, v, loaded, k)
+	}
+	k = spilled()
+	if !CompareAndSwap(c, k, k, -k) {
+		t.Errorf("CompareAndSwap(%d) failed for a spilled key", k)
+	}
+	k = spilled()
+	c.Compute(k, func(old int, ok bool) (int, ComputeOp) {
+		if !ok || old != k {
+			t.Errorf("Compute(%d) saw %d,%v for a spilled key, want %d,true", k, old, ok, k)
+		}
+		return 0, ComputeDelete
+	})
+	if _, ok := c.Get(k); ok {
+		t.Errorf("c.Get(%d) found after Compute deleted it", k)
+	}
+
+	n := c.Len() + l.Len()
+	if r := c.RemoveIf(func(k, v int) bool { return true }); r != n {
+		t.Errorf("c.RemoveIf(all)=%d, want %d", r, n)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, ok := c.Get(i); ok {
+			t.Errorf("c.Get(%d) found after RemoveIf(all)", i)
+		}
+	}
+
+	if err := l.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	c := New[int, int](100, 1000, func(k int) uint64 { return uint64(k) * 0x9e3779b97f4a7c15 })
+
+	if h := c.Health(); h != (Health{}) {
+		t.Errorf("c.Health()=%+v for a new cache, want zero", h)
+	}
+
+	// a few very hot keys saturate their counters
+	for i := 0; i < 999; i++ {
+		c.Get(i % 10)
+	}
+	h := c.Health()
+	if h.Saturation == 0 || h.Mean == 0 {
+		t.Errorf("c.Health()=%+v, want saturated counters", h)
+	}
+	if h.FillRatio != 0 || h.FalsePositiveRate != 0 {
+		t.Errorf("c.Health()=%+v, want no doorkeeper measurements before reset", h)
+	}
+
+	// overfill the doorkeeper before the next reset
+	for i := 0; i < 5000; i++ {
+		c.bouncer.Insert(uint64(i) * 0xff51afd7ed558ccd)
+	}
+	c.Get(0)
+	h = c.Health()
+	if h.FillRatio < 0.5 || h.FalsePositiveRate < 0.01 {
+		t.Errorf("c.Health()=%+v, want an overfull doorkeeper", h)
+	}
+	if h.Saturation != 0 {
+		t.Errorf("c.Health().Saturation=%v after reset, want 0", h.Saturation)
+	}
+}
+
 var SinkString string
 var SinkBool bool
 
@@ -0,0 +1,111 @@
+package tinylfu
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// HotKey is a frequently accessed key reported by TopK.
+type HotKey[K comparable] struct {
+	Key       K
+	Frequency int // estimated recent accesses
+}
+
+// TrackTopK enables TopK, tracking up to n of the most frequently looked up
+// keys.  Tracking costs a sketch estimate and a heap update per Get.
+func TrackTopK[K comparable, V any](n int) Option[K, V] {
+	return func(t *T[K, V]) { t.topk = newTopK[K](n) }
+}
+
+// TopK returns up to n of the most frequently looked up keys, most frequent
+// first.  It returns nil unless the cache was created with TrackTopK.
+func (t *T[K, V]) TopK(n int) []HotKey[K] {
+	if t.topk == nil {
+		return nil
+	}
+	return t.topk.top(n)
+}
+
+// topK is a bounded min-heap of the hottest keys seen so far
+type topK[K comparable] struct {
+	cap   int
+	keys  []HotKey[K]
+	index map[K]int // key to position in keys
+}
+
+func newTopK[K comparable](n int) *topK[K] {
+	return &topK[K]{
+		cap:   n,
+		keys:  make([]HotKey[K], 0, n),
+		index: make(map[K]int, n),
+	}
+}
+
+// offer records that key has been seen with estimated frequency f
+func (h *topK[K]) offer(key K, f byte) {
+	if i, ok := h.index[key]; ok {
+		h.keys[i].Frequency = int(f)
+		heap.Fix(h, i)
+		return
+	}
+
+	if len(h.keys) < h.cap {
+		heap.Push(h, HotKey[K]{key, int(f)})
+		return
+	}
+
+	if h.cap == 0 || int(f) <= h.keys[0].Frequency {
+		return
+	}
+
+	delete(h.index, h.keys[0].Key)
+	h.keys[0] = HotKey[K]{key, int(f)}
+	h.index[key] = 0
+	heap.Fix(h, 0)
+}
+
+// reset halves the tracked frequencies, matching the aging of the sketch
+func (h *topK[K]) reset() {
+	// halving preserves the heap ordering
+	for i := range h.keys {
+		h.keys[i].Frequency /= 2
+	}
+}
+
+// top returns the n hottest keys in descending order of frequency
+func (h *topK[K]) top(n int) []HotKey[K] {
+	if n < 0 {
+		n = 0
+	}
+
+	s := append([]HotKey[K](nil), h.keys...)
+	sort.SliceStable(s, func(i, j int) bool { return s[i].Frequency > s[j].Frequency })
+	if n < len(s) {
+		s = s[:n]
+	}
+	return s
+}
+
+// heap.Interface
+
+func (h *topK[K]) Len() int           { return len(h.keys) }
+func (h *topK[K]) Less(i, j int) bool { return h.keys[i].Frequency < h.keys[j].Frequency }
+
+func (h *topK[K]) Swap(i, j int) {
+	h.keys[i], h.keys[j] = h.keys[j], h.keys[i]
+	h.index[h.keys[i].Key] = i
+	h.index[h.keys[j].Key] = j
+}
+
+func (h *topK[K]) Push(x any) {
+	k := x.(HotKey[K])
+	h.index[k.Key] = len(h.keys)
+	h.keys = append(h.keys, k)
+}
+
+func (h *topK[K]) Pop() any {
+	k := h.keys[len(h.keys)-1]
+	h.keys = h.keys[:len(h.keys)-1]
+	delete(h.index, k.Key)
+	return k
+}